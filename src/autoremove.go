@@ -0,0 +1,178 @@
+/*
+  Blink, a powerful source-based package manager. Core of ApertureOS.
+	Want to use it for your own project?
+	Blink is completely FOSS (Free and Open Source),
+	edit, publish, use, contribute to Blink however you prefer.
+  Copyright (C) 2025-2026 Aperture OS
+
+  This program is free software: you can redistribute it and/or modify
+  it under the terms of the Apache 2.0 License as published by
+  the Apache Software Foundation, either version 2.0 of the License, or
+  any later version.
+
+  This program is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <https://www.apache.org/licenses/LICENSE-2.0>.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/Aperture-OS/eyes"
+)
+
+/****************************************************/
+// installedRecipe returns the recipe an installed package was built
+// from, as recorded at install time or snapshotted for that exact
+// version (see snapshotRecipe). never the repositories', their
+// dependency lists aren't what's on the system, and it works offline.
+// os.ErrNotExist when nothing was recorded
+/****************************************************/
+func installedRecipe(path string, pkgName string) (PackageInfo, error) {
+	pkg, err := loadRecipeDB(pkgName)
+	if err != os.ErrNotExist {
+		return pkg, err
+	}
+
+	db, err := packageDB()
+	if err != nil {
+		return PackageInfo{}, err
+	}
+	inst, ok := db.Get(pkgName)
+	if !ok {
+		return PackageInfo{}, os.ErrNotExist
+	}
+
+	latest := 0
+	if gens, err := listGenerations(); err == nil && len(gens) > 0 {
+		latest = gens[len(gens)-1].Number
+	}
+
+	data := snapshotRecipe(latest, *inst)
+	if data == nil {
+		return PackageInfo{}, os.ErrNotExist
+	}
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return PackageInfo{}, err
+	}
+	return pkg, nil
+}
+
+/****************************************************/
+// findOrphans returns the packages that were installed only as
+// dependencies and that nothing explicitly installed still needs,
+// ordered so dependents come before their dependencies (safe
+// uninstall order). "needs" = mandatory dependencies plus the
+// optional dependency choices recorded in the manifest
+/****************************************************/
+func findOrphans(path string) ([]string, error) {
+	db, err := packageDB()
+	if err != nil {
+		return nil, err
+	}
+	m := db.Manifest
+
+	var unknown []string
+	graph, err := manifestDepGraph(m, true, func(name string) (PackageInfo, error) {
+		pkg, err := installedRecipe(path, name)
+		if err == os.ErrNotExist {
+			unknown = append(unknown, name)
+			return PackageInfo{}, nil
+		}
+		return pkg, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// without its recipe there's no telling what a package needs, so
+	// nothing is safe to call an orphan
+	if len(unknown) > 0 {
+		eyes.Warnf("No recorded recipe for %v, their dependencies are unknown so every package is kept. Reinstall them with --force to record their recipes.", unknown)
+		return nil, nil
+	}
+
+	// everything reachable from an explicit package stays
+	keep := make(map[string]bool)
+	var walk func(name string)
+	walk = func(name string) {
+		if keep[name] {
+			return
+		}
+		keep[name] = true
+		for _, dep := range graph.Edges[name] {
+			walk(dep)
+		}
+	}
+
+	for _, inst := range m.Installed {
+		if inst.Reason != reasonDependency {
+			walk(inst.Name)
+		}
+	}
+
+	orphans := make(map[string]bool)
+	for _, inst := range m.Installed {
+		if !keep[inst.Name] {
+			orphans[inst.Name] = true
+		}
+	}
+
+	if len(orphans) == 0 {
+		return nil, nil
+	}
+
+	return removalOrder(graph, orphans)
+}
+
+/****************************************************/
+// autoremove uninstalls orphaned dependencies after asking,
+// dryRun only lists them
+/****************************************************/
+func autoremove(path string, dryRun bool) error {
+	orphans, err := findOrphans(path)
+	if err != nil {
+		return err
+	}
+
+	if len(orphans) == 0 {
+		eyes.Success("No orphaned packages found.")
+		return nil
+	}
+
+	eyes.Warnf("Orphaned packages (installed as dependencies, no longer needed): %d", len(orphans))
+	for _, name := range orphans {
+		fmt.Printf(" - %s\n", name)
+	}
+
+	if dryRun {
+		eyes.Infof("Dry run, nothing removed.")
+		return nil
+	}
+
+	eyes.Warn("Remove them? [ (Y)es / (N)o ]: ")
+	var input string
+	fmt.Scanln(&input)
+
+	if normalizeYesNo(input) == "no" {
+		eyes.Infof("Autoremove aborted by user.")
+		return nil
+	}
+
+	return withTransaction("autoremove", orphans, func() error {
+		for _, name := range orphans {
+			eyes.Infof("Removing orphan %s", name)
+			if err := uninstall(name, false, path); err != nil {
+				return fmt.Errorf("failed to uninstall %s: %v", name, err)
+			}
+		}
+		return nil
+	})
+}
@@ -68,8 +68,9 @@ func LoadConfig() (map[string]RepoConfig, error) {
 		}
 	}
 
-	var repos map[string]RepoConfig
-	if _, err := toml.DecodeFile(configPath, &repos); err != nil {
+	// same decoding as everywhere else (git_url, branch, priority)
+	repos, err := LoadRepos(configPath)
+	if err != nil {
 		return nil, err
 	}
 
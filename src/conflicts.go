@@ -0,0 +1,318 @@
+/*
+  Blink, a powerful source-based package manager. Core of ApertureOS.
+	Want to use it for your own project?
+	Blink is completely FOSS (Free and Open Source),
+	edit, publish, use, contribute to Blink however you prefer.
+  Copyright (C) 2025-2026 Aperture OS
+
+  This program is free software: you can redistribute it and/or modify
+  it under the terms of the Apache 2.0 License as published by
+  the Apache Software Foundation, either version 2.0 of the License, or
+  any later version.
+
+  This program is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <https://www.apache.org/licenses/LICENSE-2.0>.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Aperture-OS/eyes"
+)
+
+/****************************************************/
+// File conflicts: before a staged tree is merged into the system,
+// every path in it is checked against the installed-file database and
+// the live filesystem. a path owned by another package or already on
+// disk (untracked) is a conflict and the install is aborted, unless
+// the path matches one of the --overwrite globs. overwriting a file owned
+// by another package moves the ownership to the new package
+/****************************************************/
+
+// fileConflict is a staged path that would clobber something
+type fileConflict struct {
+	Path  string
+	Owner string // owning package, empty if the file is untracked
+}
+
+func (c fileConflict) String() string {
+	if c.Owner == "" {
+		return fmt.Sprintf("%s exists in filesystem (not owned by any package)", c.Path)
+	}
+	return fmt.Sprintf("%s is owned by %s", c.Path, c.Owner)
+}
+
+/****************************************************/
+// buildOwnerIndex maps every tracked path to the packages owning it
+// directories can legitimately have many owners, files normally one
+// exclude skips a package (the one being (re)installed)
+/****************************************************/
+func buildOwnerIndex(exclude string) (map[string][]string, error) {
+	pdb, err := packageDB()
+	if err != nil {
+		return nil, err
+	}
+	m := pdb.Manifest
+
+	index := make(map[string][]string)
+	for _, inst := range m.Installed {
+		if inst.Name == exclude {
+			continue
+		}
+
+		db, err := loadFileDB(inst.Name)
+		if err == os.ErrNotExist {
+			continue // installed before file tracking
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, f := range db.Files {
+			index[f.Path] = append(index[f.Path], inst.Name)
+		}
+	}
+
+	return index, nil
+}
+
+/****************************************************/
+// checkConflicts walks stageDir and returns every path that would
+// overwrite a file of another package or an untracked file in root
+/****************************************************/
+func checkConflicts(pkgName, stageDir, root string) ([]fileConflict, error) {
+	eyes.Infof("Checking %s for file conflicts", pkgName)
+
+	owners, err := buildOwnerIndex(pkgName)
+	if err != nil {
+		return nil, err
+	}
+
+	// files the package already owns from a previous install aren't conflicts
+	own := make(map[string]bool)
+	db, dbErr := loadFileDB(pkgName)
+	if dbErr == nil {
+		for _, f := range db.Files {
+			own[f.Path] = true
+		}
+	}
+
+	// installed before file tracking: its files are on disk but nobody
+	// owns them. the ones identical to what's staged get adopted, so a
+	// --force reinstall gives it a file database
+	adopt := dbErr == os.ErrNotExist && isInstalled(pkgName)
+
+	var conflicts []fileConflict
+
+	err = filepath.Walk(stageDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(stageDir, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+
+		abs := filepath.Join("/", rel)
+		live, lerr := os.Lstat(filepath.Join(root, rel))
+
+		// directories are shared, only a non-directory in the way is a problem
+		if info.IsDir() {
+			if lerr == nil && !live.IsDir() && live.Mode()&os.ModeSymlink == 0 {
+				conflicts = append(conflicts, fileConflict{Path: abs, Owner: firstOwner(owners[abs])})
+			}
+			return nil
+		}
+
+		for _, owner := range owners[abs] {
+			conflicts = append(conflicts, fileConflict{Path: abs, Owner: owner})
+		}
+		if len(owners[abs]) > 0 {
+			return nil
+		}
+
+		if lerr == nil && !own[abs] {
+			if adopt && sameContent(path, info, filepath.Join(root, rel), live) {
+				return nil
+			}
+			conflicts = append(conflicts, fileConflict{Path: abs})
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return conflicts, nil
+}
+
+/****************************************************/
+// sameContent tells whether a staged file and the live one are the
+// same: both regular files with the same sha256, or both symlinks
+// pointing to the same target
+/****************************************************/
+func sameContent(staged string, stagedInfo os.FileInfo, live string, liveInfo os.FileInfo) bool {
+	switch {
+	case stagedInfo.Mode()&os.ModeSymlink != 0 && liveInfo.Mode()&os.ModeSymlink != 0:
+		a, errA := os.Readlink(staged)
+		b, errB := os.Readlink(live)
+		return errA == nil && errB == nil && a == b
+
+	case stagedInfo.Mode().IsRegular() && liveInfo.Mode().IsRegular():
+		if stagedInfo.Size() != liveInfo.Size() {
+			return false
+		}
+		a, errA := fileSHA256(staged)
+		b, errB := fileSHA256(live)
+		return errA == nil && errB == nil && a == b
+	}
+
+	return false
+}
+
+/****************************************************/
+// resolveConflicts aborts on conflicts that don't match an --overwrite
+// glob, and takes the overwritten paths away from their old owners
+/****************************************************/
+func resolveConflicts(pkgName string, conflicts []fileConflict, overwrite []string) error {
+	if len(conflicts) == 0 {
+		return nil
+	}
+
+	var blocking []fileConflict
+	disown := make(map[string][]string) // owner -> paths
+
+	for _, c := range conflicts {
+		if !matchAnyGlob(overwrite, c.Path) {
+			blocking = append(blocking, c)
+			continue
+		}
+		eyes.Warnf("Overwriting %s", c)
+		if c.Owner != "" {
+			disown[c.Owner] = append(disown[c.Owner], c.Path)
+		}
+	}
+
+	if len(blocking) > 0 {
+		eyes.Errorf("%s: %d conflicting file(s):", pkgName, len(blocking))
+		for _, c := range blocking {
+			fmt.Printf(" - %s\n", c)
+		}
+		return fmt.Errorf("file conflicts detected for %s, use --overwrite <glob> to overwrite them", pkgName)
+	}
+
+	owners := make([]string, 0, len(disown))
+	for owner := range disown {
+		owners = append(owners, owner)
+	}
+	sort.Strings(owners)
+
+	for _, owner := range owners {
+		if err := disownFiles(owner, disown[owner]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/****************************************************/
+// disownFiles drops paths from a package's file database, used when
+// another package takes them over with --overwrite
+/****************************************************/
+func disownFiles(pkgName string, paths []string) error {
+	db, err := loadFileDB(pkgName)
+	if err != nil {
+		return err
+	}
+
+	drop := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		drop[p] = true
+	}
+
+	kept := db.Files[:0]
+	for _, f := range db.Files {
+		if !drop[f.Path] {
+			kept = append(kept, f)
+		}
+	}
+	db.Files = kept
+
+	eyes.Infof("%d file(s) of %s are now owned by another package", len(paths), pkgName)
+	return saveFileDB(db)
+}
+
+func firstOwner(owners []string) string {
+	if len(owners) == 0 {
+		return ""
+	}
+	return owners[0]
+}
+
+/****************************************************/
+// globMatch matches a path against a shell-like glob where
+// "*" also matches "/" (like pacman's --overwrite), so "/usr/lib/*"
+// covers everything below /usr/lib. "?" and [...] work as usual
+/****************************************************/
+func globMatch(pattern, name string) bool {
+	var b strings.Builder
+	b.WriteString("^")
+
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch c {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		case '[':
+			end := strings.IndexByte(pattern[i:], ']')
+			if end < 0 {
+				b.WriteString(regexp.QuoteMeta("["))
+				continue
+			}
+			class := pattern[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	b.WriteString("$")
+
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return false
+	}
+	return re.MatchString(name)
+}
+
+func matchAnyGlob(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if globMatch(p, name) {
+			return true
+		}
+	}
+	return false
+}
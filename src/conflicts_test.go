@@ -0,0 +1,57 @@
+/*
+  Blink, a powerful source-based package manager. Core of ApertureOS.
+	Want to use it for your own project?
+	Blink is completely FOSS (Free and Open Source),
+	edit, publish, use, contribute to Blink however you prefer.
+  Copyright (C) 2025-2026 Aperture OS
+
+  This program is free software: you can redistribute it and/or modify
+  it under the terms of the Apache 2.0 License as published by
+  the Apache Software Foundation, either version 2.0 of the License, or
+  any later version.
+
+  This program is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <https://www.apache.org/licenses/LICENSE-2.0>.
+*/
+
+package main
+
+import "testing"
+
+func TestGlobMatch(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"/usr/bin/foo", "/usr/bin/foo", true},
+		{"/usr/bin/foo", "/usr/bin/foobar", false},
+
+		// "*" crosses "/", like pacman's --overwrite
+		{"/usr/lib/*", "/usr/lib/libfoo.so", true},
+		{"/usr/lib/*", "/usr/lib/foo/bar.so", true},
+		{"/usr/lib/*", "/usr/lib", false},
+		{"*.conf", "/etc/foo/bar.conf", true},
+
+		{"/usr/bin/fo?", "/usr/bin/foo", true},
+		{"/usr/bin/fo?", "/usr/bin/fooo", false},
+		{"/usr/lib/lib[ab].so", "/usr/lib/liba.so", true},
+		{"/usr/lib/lib[ab].so", "/usr/lib/libc.so", false},
+		{"/usr/lib/lib[!ab].so", "/usr/lib/libc.so", true},
+		{"/usr/lib/lib[!ab].so", "/usr/lib/liba.so", false},
+
+		// regexp characters are literal
+		{"/usr/lib/libc++.so", "/usr/lib/libc++.so", true},
+		{"/usr/lib/a.b", "/usr/lib/axb", false},
+		{"/usr/lib/[", "/usr/lib/[", true},
+	}
+
+	for _, tt := range tests {
+		if got := globMatch(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("globMatch(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
@@ -22,6 +22,7 @@ package main
 
 import (
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -34,10 +35,27 @@ import (
 // TIP: Check out github.com/Aperture-OS/togosort-dfs docs and comments in source code
 
 
+/****************************************************/
+// depRequirement remembers which package asked for a dependency
+// and which version constraint it put on it, so we can tell the user
+// exactly who is responsible when a version doesn't fit
+/****************************************************/
+type depRequirement struct {
+	From       string
+	Constraint constraintSet
+}
+
+// presetOptDeps holds optional dependency choices made ahead of time
+// (rollback restores a generation's, updates keep the recorded ones),
+// handleOptionalDeps uses them instead of asking. a group they don't
+// cover is new to the recipe and still gets asked
+var presetOptDeps map[string][]OptSelection
+
 /****************************************************/
 //
 // Recursive helper to build dependency graph
 // IMPORTANT: AddEdge(A, B) == A depends on B
+// every edge also records its version constraint in reqs
 //
 /****************************************************/
 func buildDepGraph(
@@ -45,6 +63,7 @@ func buildDepGraph(
 	pkgName string,
 	path string,
 	visited map[string]bool,
+	reqs map[string][]depRequirement,
 ) error {
 	if visited[pkgName] {
 		return nil
@@ -56,11 +75,17 @@ func buildDepGraph(
 		return fmt.Errorf("failed to fetch package %s: %v", pkgName, err)
 	}
 
-	for dep := range pkg.Dependencies {
+	for dep, constraint := range pkg.Dependencies {
+		set, err := parseConstraints(constraint)
+		if err != nil {
+			return fmt.Errorf("invalid version constraint for dependency %s of %s: %v", dep, pkgName, err)
+		}
+		reqs[dep] = append(reqs[dep], depRequirement{From: pkgName, Constraint: set})
+
 		// pkgName depends on dep
 		graph.AddEdge(pkgName, dep)
 
-		if err := buildDepGraph(graph, dep, path, visited); err != nil {
+		if err := buildDepGraph(graph, dep, path, visited, reqs); err != nil {
 			return err
 		}
 	}
@@ -68,6 +93,120 @@ func buildDepGraph(
 	return nil
 }
 
+/****************************************************/
+//
+// checkDepConstraints checks every recorded requirement against
+// the installed version first, and the repo recipe only when the
+// dependency needs installing or upgrading. a recipe that can't satisfy
+// its constraint is then a hard error, since there is nothing we could
+// install to fix it, same for a held package that would need to move.
+// installed packages that don't fit are returned (sorted) so the caller
+// can offer an upgrade
+//
+/****************************************************/
+func checkDepConstraints(reqs map[string][]depRequirement, path string) ([]string, error) {
+	var outdated []string
+
+	db, err := packageDB()
+	if err != nil {
+		return nil, err
+	}
+	m := db.Manifest
+
+	// sorted, so the same broken constraint is reported every run
+	deps := make([]string, 0, len(reqs))
+	for dep := range reqs {
+		deps = append(deps, dep)
+	}
+	sort.Strings(deps)
+
+	for _, dep := range deps {
+		rs := reqs[dep]
+
+		installed, exists, err := manifestHas(dep)
+		if err != nil {
+			return nil, err
+		}
+
+		// installed and good enough, wherever the repository moved since
+		var unmet *depRequirement
+		if exists {
+			for i := range rs {
+				if !rs[i].Constraint.satisfiedBy(installed.evr()) {
+					unmet = &rs[i]
+					break
+				}
+			}
+			if unmet == nil {
+				continue
+			}
+		}
+
+		pkg, err := fetchpkg(path, false, dep, true)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch package %s: %v", dep, err)
+		}
+
+		for _, r := range rs {
+			if !r.Constraint.satisfiedBy(pkg.evr()) {
+				return nil, fmt.Errorf(
+					"%s requires %s %s, but the repository only provides %s %s",
+					r.From, dep, r.Constraint, dep, pkg.evr(),
+				)
+			}
+		}
+
+		if !exists {
+			// a pinned dependency that isn't installed yet must match its pin
+			if err := checkHold(m, pkg); err != nil {
+				return nil, fmt.Errorf("cannot install dependency %s: %v", dep, err)
+			}
+			continue
+		}
+
+		if hold := findHold(m, dep); hold != nil && !hold.pins(pkg.evr()) {
+			return nil, fmt.Errorf(
+				"%s requires %s %s, but installed %s %s is held (%s), run 'blink unhold %s' to allow the upgrade",
+				unmet.From, dep, unmet.Constraint, dep, installed.evr(), hold, dep,
+			)
+		}
+		eyes.Warnf(
+			"Installed %s %s does not satisfy %s (required by %s), repository has %s",
+			dep, installed.evr(), unmet.Constraint, unmet.From, pkg.evr(),
+		)
+		outdated = append(outdated, dep)
+	}
+
+	sort.Strings(outdated)
+	return outdated, nil
+}
+
+/****************************************************/
+//
+// confirmDepUpgrades asks the user whether installed dependencies
+// that are too old/new for pkgName should be reinstalled from the repo
+// saying no refuses the whole install, a broken dependency is worse than
+// no package at all
+//
+/****************************************************/
+func confirmDepUpgrades(pkgName string, outdated []string) error {
+	if len(outdated) == 0 {
+		return nil
+	}
+
+	eyes.Warnf("Installed dependencies do not satisfy the versions required by %s: %v", pkgName, outdated)
+	eyes.Warn("Do you want to upgrade them? [ (Y)es / (N)o ]: ")
+
+	var input string
+	fmt.Scanln(&input)
+
+	if normalizeYesNo(input) == "no" {
+		return fmt.Errorf("cannot install %s: installed dependencies %v do not satisfy version constraints", pkgName, outdated)
+	}
+
+	return nil
+}
+
 /****************************************************/
 //
 // Handle mandatory dependencies (DFS + topo)
@@ -76,8 +215,9 @@ func buildDepGraph(
 func handleMandatoryDeps(pkgName, path string) error {
 	graph := togosort.NewGraph()
 	visited := make(map[string]bool)
+	reqs := make(map[string][]depRequirement)
 
-	if err := buildDepGraph(graph, pkgName, path, visited); err != nil {
+	if err := buildDepGraph(graph, pkgName, path, visited, reqs); err != nil {
 		return err
 	}
 
@@ -88,6 +228,18 @@ func handleMandatoryDeps(pkgName, path string) error {
 
 	order := graph.TopoSort()
 
+	// version constraints, refuse or upgrade before touching anything
+	outdated, err := checkDepConstraints(reqs, path)
+	if err != nil {
+		return err
+	}
+	if err := confirmDepUpgrades(pkgName, outdated); err != nil {
+		return err
+	}
+	if err := upgradeDeps(order, outdated, path); err != nil {
+		return err
+	}
+
 	var missing []string
 	for _, dep := range order {
 		if dep == pkgName {
@@ -114,7 +266,8 @@ func handleMandatoryDeps(pkgName, path string) error {
 
 	switch input {
 	case "n", "no":
-		eyes.Fatalf("Cannot continue without mandatory dependencies.")
+		// an error, not Fatalf, so the transaction gets rolled back
+		return fmt.Errorf("cannot continue without mandatory dependencies %v", missing)
 	case "bypass-donotuse":
 		eyes.Warnf(`[DEVELOPER ONLY/INSECURE] Bypassing mandatory dependencies check (press CTRL+C to cancel).
 This is not secure, your package could break! To fix this properly rerun the command you just ran and install the missing dependencies
@@ -128,7 +281,7 @@ our Discord server. Run 'blink support' for more informations.`)
 			continue
 		}
 		eyes.Infof("Installing dependency %s", dep)
-		if err := install(dep, false, path); err != nil {
+		if err := install(dep, false, path, reasonDependency); err != nil {
 			return fmt.Errorf("failed to install dependency %s: %v", dep, err)
 		}
 	}
@@ -139,15 +292,33 @@ our Discord server. Run 'blink support' for more informations.`)
 /****************************************************/
 //
 // Handle optional dependencies (DFS + topo per choice)
+// returns the choice made for every group, for the manifest
 //
 /****************************************************/
-func handleOptionalDeps(pkgName string, path string) error {
+func handleOptionalDeps(pkgName string, path string) ([]OptSelection, error) {
 	pkg, err := fetchpkg(path, false, pkgName, true)
 	if err != nil {
-		return fmt.Errorf("failed to fetch package %s: %v", pkgName, err)
+		return nil, fmt.Errorf("failed to fetch package %s: %v", pkgName, err)
 	}
 
+	var selections []OptSelection
+
 	for _, group := range pkg.OptDeps {
+		// decided ahead of time (rollback, update), don't ask
+		preset, found := OptSelection{}, false
+		for _, p := range presetOptDeps[pkgName] {
+			if p.Group == group.ID {
+				preset, found = p, true
+			}
+		}
+		if found {
+			if preset.Choice != "" && !isInstalled(preset.Choice) {
+				eyes.Warnf("Optional dependency %s of %s is not installed", preset.Choice, pkgName)
+			}
+			selections = append(selections, preset)
+			continue
+		}
+
 		var installed []string
 		var notInstalled []string
 
@@ -192,34 +363,255 @@ func handleOptionalDeps(pkgName string, path string) error {
 
 		if choice == 0 {
 			eyes.Infof("Skipping optional group %d", group.ID)
+			selections = append(selections, OptSelection{Group: group.ID})
 			continue
 		}
 
 		selected := notInstalled[choice-1]
+		selections = append(selections, OptSelection{Group: group.ID, Choice: selected})
 
 		graph := togosort.NewGraph()
 		visited := make(map[string]bool)
+		reqs := make(map[string][]depRequirement)
 
-		if err := buildDepGraph(graph, selected, path, visited); err != nil {
-			return err
+		if err := buildDepGraph(graph, selected, path, visited, reqs); err != nil {
+			return nil, err
 		}
 
 		if err := graph.DFS([]string{selected}); err != nil {
-			return fmt.Errorf("dependency cycle detected: %v", err)
+			return nil, fmt.Errorf("dependency cycle detected: %v", err)
 		}
 
 		order := graph.TopoSort()
 
+		outdated, err := checkDepConstraints(reqs, path)
+		if err != nil {
+			return nil, err
+		}
+		if err := confirmDepUpgrades(selected, outdated); err != nil {
+			return nil, err
+		}
+		if err := upgradeDeps(order, outdated, path); err != nil {
+			return nil, err
+		}
+
 		for _, dep := range order {
 			if isInstalled(dep) {
 				continue
 			}
 			eyes.Infof("Installing optional dependency %s", dep)
-			if err := install(dep, false, path); err != nil {
-				return fmt.Errorf("failed to install optional dependency %s: %v", dep, err)
+			if err := install(dep, false, path, reasonDependency); err != nil {
+				return nil, fmt.Errorf("failed to install optional dependency %s: %v", dep, err)
 			}
 		}
 	}
 
+	return selections, nil
+}
+
+/****************************************************/
+//
+// upgradeDeps reinstalls the outdated dependencies from the repo,
+// walking the topo order so dependencies of dependencies go first
+//
+/****************************************************/
+func upgradeDeps(order []string, outdated []string, path string) error {
+	if len(outdated) == 0 {
+		return nil
+	}
+
+	wanted := make(map[string]bool, len(outdated))
+	for _, dep := range outdated {
+		wanted[dep] = true
+	}
+
+	for _, dep := range order {
+		if !wanted[dep] {
+			continue
+		}
+		eyes.Infof("Upgrading dependency %s", dep)
+		if err := upgrade(dep, path); err != nil {
+			return fmt.Errorf("failed to upgrade dependency %s: %v", dep, err)
+		}
+	}
+
 	return nil
 }
+
+/****************************************************/
+//
+// installedDepGraph builds the dependency graph of what is installed,
+// from the installed packages' recipes. edges only point at installed
+// packages. withOptional also adds the optional dependency choices
+// recorded in the manifest (they keep a package around, but removing
+// them doesn't break anything)
+//
+/****************************************************/
+func installedDepGraph(m Manifest, path string, withOptional bool) (*togosort.Graph, error) {
+	return manifestDepGraph(m, withOptional, func(name string) (PackageInfo, error) {
+		return installedRecipe(path, name)
+	})
+}
+
+/****************************************************/
+//
+// manifestDepGraph is installedDepGraph with the recipes coming from
+// recipeOf, rollback uses it to graph a generation from its snapshot
+//
+/****************************************************/
+func manifestDepGraph(m Manifest, withOptional bool, recipeOf func(name string) (PackageInfo, error)) (*togosort.Graph, error) {
+	installed := make(map[string]bool, len(m.Installed))
+	for _, inst := range m.Installed {
+		installed[inst.Name] = true
+	}
+
+	// A -> B == A depends on B
+	graph := togosort.NewGraph()
+	for _, inst := range m.Installed {
+		graph.AddNode(inst.Name)
+
+		pkg, err := recipeOf(inst.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read recipe of installed package %s: %v", inst.Name, err)
+		}
+
+		for dep := range pkg.Dependencies {
+			if installed[dep] {
+				graph.AddEdge(inst.Name, dep)
+			}
+		}
+
+		if !withOptional {
+			continue
+		}
+		for _, sel := range inst.OptDeps {
+			if sel.Choice != "" && installed[sel.Choice] {
+				graph.AddEdge(inst.Name, sel.Choice)
+			}
+		}
+	}
+
+	return graph, nil
+}
+
+/****************************************************/
+//
+// reverseDeps inverts a dependency graph: dependency -> its dependents
+//
+/****************************************************/
+func reverseDeps(graph *togosort.Graph) map[string][]string {
+	rdeps := make(map[string][]string)
+	for pkg, deps := range graph.Edges {
+		for _, dep := range deps {
+			rdeps[dep] = append(rdeps[dep], pkg)
+		}
+	}
+	for dep := range rdeps {
+		sort.Strings(rdeps[dep])
+	}
+	return rdeps
+}
+
+/****************************************************/
+//
+// removalOrder sorts a set of packages for uninstalling: dependents
+// before their dependencies, so nothing is ever left depending on an
+// already removed package. it's TopoSort on the subgraph, reversed
+//
+/****************************************************/
+func removalOrder(graph *togosort.Graph, set map[string]bool) ([]string, error) {
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	sub := togosort.NewGraph()
+	for _, name := range names {
+		sub.AddNode(name)
+		for _, dep := range graph.Edges[name] {
+			if set[dep] {
+				sub.AddEdge(name, dep)
+			}
+		}
+	}
+
+	if err := sub.DFS(names); err != nil {
+		return nil, fmt.Errorf("dependency cycle detected: %v", err)
+	}
+
+	order := sub.TopoSort()
+	for i, j := 0, len(order)-1; i < j; i, j = i+1, j-1 {
+		order[i], order[j] = order[j], order[i]
+	}
+
+	return order, nil
+}
+
+/****************************************************/
+//
+// planUninstall works out what uninstalling names really means
+// installed packages that still depend on them block the uninstall,
+// unless cascade (remove the dependents too, recursively) or nodeps
+// (remove anyway, log what breaks). returns the removal order
+//
+/****************************************************/
+func planUninstall(names []string, path string, cascade bool, nodeps bool) ([]string, error) {
+	db, err := packageDB()
+	if err != nil {
+		return nil, err
+	}
+	m := db.Manifest
+
+	graph, err := installedDepGraph(m, path, false)
+	if err != nil {
+		return nil, err
+	}
+	rdeps := reverseDeps(graph)
+
+	remove := make(map[string]bool)
+	for _, name := range names {
+		remove[name] = true
+	}
+
+	if cascade {
+		queue := append([]string{}, names...)
+		for len(queue) > 0 {
+			name := queue[0]
+			queue = queue[1:]
+			for _, dependent := range rdeps[name] {
+				if !remove[dependent] {
+					eyes.Warnf("%s depends on %s, removing it too (--cascade)", dependent, name)
+					remove[dependent] = true
+					queue = append(queue, dependent)
+				}
+			}
+		}
+	} else {
+		var blocked []string
+		for _, name := range names {
+			var broken []string
+			for _, dependent := range rdeps[name] {
+				if !remove[dependent] {
+					broken = append(broken, dependent)
+				}
+			}
+			if len(broken) == 0 {
+				continue
+			}
+
+			if nodeps {
+				eyes.Warnf("[--nodeps] Removing %s breaks installed packages that depend on it: %v", name, broken)
+				continue
+			}
+			blocked = append(blocked, fmt.Sprintf("%s (required by %s)", name, strings.Join(broken, ", ")))
+		}
+
+		if len(blocked) > 0 {
+			return nil, fmt.Errorf("refusing to uninstall, other installed packages depend on: %s. Use --cascade to remove them too or --nodeps to force",
+				strings.Join(blocked, "; "))
+		}
+	}
+
+	return removalOrder(graph, remove)
+}
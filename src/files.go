@@ -0,0 +1,488 @@
+/*
+  Blink, a powerful source-based package manager. Core of ApertureOS.
+	Want to use it for your own project?
+	Blink is completely FOSS (Free and Open Source),
+	edit, publish, use, contribute to Blink however you prefer.
+  Copyright (C) 2025-2026 Aperture OS
+
+  This program is free software: you can redistribute it and/or modify
+  it under the terms of the Apache 2.0 License as published by
+  the Apache Software Foundation, either version 2.0 of the License, or
+  any later version.
+
+  This program is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <https://www.apache.org/licenses/LICENSE-2.0>.
+*/
+
+/****************************************************/
+// Per-package file database
+// every package gets a directory under pkgDBPath (next to manifest.toml)
+// with a files.toml listing every file, directory and symlink it put on
+// the system, with mode and sha256, plus the recipe.json it was built
+// from. builds install into a staging root (DESTDIR) first, then
+// mergeStaged copies that tree into the real root and records what it
+// copied. no more "make install straight into /" with zero idea of
+// what ended up where
+/****************************************************/
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/Aperture-OS/eyes"
+)
+
+// file types stored in FileEntry.Type
+const (
+	fileTypeFile    = "file"
+	fileTypeDir     = "dir"
+	fileTypeSymlink = "symlink"
+)
+
+/****************************************************/
+// FileEntry is a single path owned by a package
+// Path is always absolute and relative to the install root ("/usr/bin/foo")
+/****************************************************/
+type FileEntry struct {
+	Path   string `toml:"path" json:"path"`
+	Type   string `toml:"type" json:"type"`                         // file, dir or symlink
+	Mode   uint32 `toml:"mode" json:"mode"`                         // permission bits (os.FileMode.Perm + setuid/gid/sticky)
+	Sha256 string `toml:"sha256,omitempty" json:"sha256,omitempty"` // regular files only
+	Target string `toml:"target,omitempty" json:"target,omitempty"` // symlinks only
+	Config bool   `toml:"config,omitempty" json:"config,omitempty"` // matches the recipe's build.config
+}
+
+/****************************************************/
+// FileDB is the files.toml of a single package
+/****************************************************/
+type FileDB struct {
+	Package string      `toml:"package"`
+	Files   []FileEntry `toml:"files"`
+}
+
+// pkgDBDir returns the database directory of a package
+func pkgDBDir(name string) string {
+	return filepath.Join(pkgDBPath, name)
+}
+
+// fileDBPath returns the path of a package's files.toml
+func fileDBPath(name string) string {
+	return filepath.Join(pkgDBDir(name), "files.toml")
+}
+
+/****************************************************/
+// loadFileDB loads the file list of an installed package
+// packages installed before file tracking existed have none,
+// in that case an empty FileDB and os.ErrNotExist are returned
+/****************************************************/
+func loadFileDB(name string) (FileDB, error) {
+	db := FileDB{Package: name}
+
+	if _, err := os.Stat(fileDBPath(name)); os.IsNotExist(err) {
+		return db, os.ErrNotExist
+	}
+
+	if _, err := toml.DecodeFile(fileDBPath(name), &db); err != nil {
+		return db, fmt.Errorf("failed to decode file database of %s: %v", name, err)
+	}
+
+	return db, nil
+}
+
+/****************************************************/
+// saveFileDB writes the file list of a package, same tmp + rename
+// trick as saveManifest so a crash never leaves half a file behind
+/****************************************************/
+func saveFileDB(db FileDB) error {
+	eyes.Infof("Recording %d files for %s", len(db.Files), db.Package)
+
+	if err := journalBeforeDB(db.Package); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(pkgDBDir(db.Package), 0755); err != nil {
+		return err
+	}
+
+	sort.Slice(db.Files, func(i, j int) bool { return db.Files[i].Path < db.Files[j].Path })
+
+	target := fileDBPath(db.Package)
+	tmp := target + ".tmp"
+
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := toml.NewEncoder(file).Encode(db); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, target)
+}
+
+/****************************************************/
+// the package database also keeps the exact recipe a package was
+// installed from (recipe.json), the cache and the repositories move on
+// after a sync but uninstall hooks, dependencies and config globs have
+// to be the ones of what is actually on the system
+/****************************************************/
+
+// recipeDBPath returns the path of a package's recipe.json
+func recipeDBPath(name string) string {
+	return filepath.Join(pkgDBDir(name), "recipe.json")
+}
+
+// loadRecipeDB loads the recorded recipe of an installed package,
+// os.ErrNotExist for packages installed before recipes were recorded
+func loadRecipeDB(name string) (PackageInfo, error) {
+	var pkg PackageInfo
+
+	data, err := os.ReadFile(recipeDBPath(name))
+	if os.IsNotExist(err) {
+		return pkg, os.ErrNotExist
+	}
+	if err != nil {
+		return pkg, err
+	}
+
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return pkg, fmt.Errorf("failed to decode recorded recipe of %s: %v", name, err)
+	}
+	return pkg, nil
+}
+
+// saveRecipeDB records the recipe a package was installed from
+func saveRecipeDB(pkg PackageInfo) error {
+	if err := journalBeforeDB(pkg.Name); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(pkgDBDir(pkg.Name), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(pkg, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	target := recipeDBPath(pkg.Name)
+	tmp := target + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, target)
+}
+
+/****************************************************/
+// removeFileDB deletes the whole database directory of a package
+/****************************************************/
+func removeFileDB(name string) error {
+	if err := journalBeforeDB(name); err != nil {
+		return err
+	}
+	return os.RemoveAll(pkgDBDir(name))
+}
+
+/****************************************************/
+// prepareStage creates a fresh, empty staging root for a package
+/****************************************************/
+func prepareStage(name string) (string, error) {
+	stageDir := filepath.Join(stageRoot, name)
+
+	_ = os.RemoveAll(stageDir)
+	if err := os.MkdirAll(stageDir, 0755); err != nil {
+		return "", err
+	}
+
+	return stageDir, nil
+}
+
+/****************************************************/
+// mergeStaged copies everything under stageDir into root and returns
+// a FileEntry for every path it created. directories that already
+// exist in root keep their mode (we don't want a package chmod'ing /usr)
+// files are written next to the target and renamed over it, so a
+// running binary is replaced instead of truncated under its feet
+/****************************************************/
+func mergeStaged(stageDir, root string) ([]FileEntry, error) {
+	eyes.Infof("Merging %s into %s", stageDir, root)
+
+	var entries []FileEntry
+
+	err := filepath.Walk(stageDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(stageDir, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+
+		entry := FileEntry{
+			Path: filepath.Join("/", rel),
+			Mode: uint32(info.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)),
+		}
+		target := filepath.Join(root, rel)
+
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			linkTarget, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			entry.Type = fileTypeSymlink
+			entry.Target = linkTarget
+
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+
+			if err := journalBeforeWrite(target); err != nil {
+				return err
+			}
+
+			// replace whatever is there, a symlink can't be overwritten in place
+			tmp := target + ".blink-new"
+			_ = os.Remove(tmp)
+			if err := os.Symlink(linkTarget, tmp); err != nil {
+				return err
+			}
+			if err := os.Rename(tmp, target); err != nil {
+				return err
+			}
+
+		case info.IsDir():
+			entry.Type = fileTypeDir
+
+			if _, err := os.Lstat(target); os.IsNotExist(err) {
+				if err := journalBeforeWrite(target); err != nil {
+					return err
+				}
+				if err := os.MkdirAll(target, info.Mode().Perm()); err != nil {
+					return err
+				}
+				if err := os.Chmod(target, info.Mode()&os.ModePerm); err != nil {
+					return err
+				}
+			} else if live, err := os.Stat(target); err == nil && live.IsDir() {
+				// already there (a shared dir, or a symlink to one like
+				// /lib -> usr/lib) and it keeps its own mode, record that.
+				// the link itself is never ours, it stays a "dir" entry
+				entry.Mode = uint32(live.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky))
+			}
+
+		case info.Mode().IsRegular():
+			entry.Type = fileTypeFile
+
+			sum, err := fileSHA256(path)
+			if err != nil {
+				return err
+			}
+			entry.Sha256 = sum
+
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			if err := journalBeforeWrite(target); err != nil {
+				return err
+			}
+			if err := copyFileAtomic(path, target, info.Mode()); err != nil {
+				return err
+			}
+
+		default:
+			eyes.Warnf("Skipping special file %s", entry.Path)
+			return nil
+		}
+
+		entries = append(entries, entry)
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge staged files: %v", err)
+	}
+
+	return entries, nil
+}
+
+/****************************************************/
+// copyFileAtomic copies src to dst through a temporary file in the
+// same directory, then renames it into place
+/****************************************************/
+func copyFileAtomic(src, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := dst + ".blink-new"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	// OpenFile's mode goes through umask, set it for real (+ setuid & co)
+	if err := os.Chmod(tmp, mode&(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky)); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Rename(tmp, dst)
+}
+
+/****************************************************/
+// stageAndRecord checks a package's staged tree for file conflicts,
+// merges it into root and writes its file database, the last step
+// before addToManifest
+// on a reinstall/update, files the old version had but the new one
+// doesn't are removed, otherwise they'd stay around forever untracked
+/****************************************************/
+func stageAndRecord(pkg PackageInfo, stageDir, root string) error {
+	pkgName := pkg.Name
+
+	conflicts, err := checkConflicts(pkgName, stageDir, root)
+	if err != nil {
+		return err
+	}
+	if err := resolveConflicts(pkgName, conflicts, overwriteGlobs); err != nil {
+		return err
+	}
+
+	old, oldErr := loadFileDB(pkgName)
+
+	entries, err := mergeStaged(stageDir, root)
+	if err != nil {
+		return err
+	}
+
+	for i := range entries {
+		if entries[i].Type == fileTypeFile && matchAnyGlob(pkg.Build.Config, entries[i].Path) {
+			entries[i].Config = true
+		}
+	}
+
+	if err := saveFileDB(FileDB{Package: pkgName, Files: entries}); err != nil {
+		return err
+	}
+
+	if oldErr == nil {
+		current := make(map[string]bool, len(entries))
+		for _, e := range entries {
+			current[e.Path] = true
+		}
+
+		var stale []FileEntry
+		for _, e := range old.Files {
+			if !current[e.Path] {
+				stale = append(stale, e)
+			}
+		}
+
+		if len(stale) > 0 {
+			eyes.Infof("Removing %d files no longer shipped by %s", len(stale), pkgName)
+			return removeRecordedFiles(stale, root)
+		}
+	}
+
+	return nil
+}
+
+/****************************************************/
+// removeRecordedFiles deletes the given entries from root
+// files and symlinks go first, then directories deepest first, and
+// only if they're empty, so shared dirs like /usr/bin survive
+// already missing paths are fine, the goal is for them to be gone
+/****************************************************/
+func removeRecordedFiles(entries []FileEntry, root string) error {
+	var dirs []FileEntry
+	var failed []string
+
+	for _, e := range entries {
+		if e.Type == fileTypeDir {
+			dirs = append(dirs, e)
+			continue
+		}
+
+		target := filepath.Join(root, e.Path)
+		info, err := os.Lstat(target)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			failed = append(failed, e.Path)
+			continue
+		}
+
+		// someone replaced the file with a directory, leave it alone
+		if info.IsDir() {
+			eyes.Warnf("%s is now a directory, not removing it", e.Path)
+			continue
+		}
+
+		if err := journalBeforeRemove(target); err != nil {
+			return err
+		}
+		if err := os.Remove(target); err != nil {
+			eyes.Warnf("Failed to remove %s: %v", e.Path, err)
+			failed = append(failed, e.Path)
+		}
+	}
+
+	// deepest first, /usr/share/foo/bar before /usr/share/foo
+	sort.Slice(dirs, func(i, j int) bool { return len(dirs[i].Path) > len(dirs[j].Path) })
+
+	for _, d := range dirs {
+		target := filepath.Join(root, d.Path)
+
+		// a symlink standing in for the dir (/lib -> usr/lib) isn't ours
+		if info, err := os.Lstat(target); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			continue
+		}
+
+		entries, err := os.ReadDir(target)
+		if err != nil || len(entries) > 0 {
+			continue // missing or still used by something else
+		}
+		if err := journalBeforeRemove(target); err != nil {
+			return err
+		}
+		if err := os.Remove(target); err != nil {
+			eyes.Warnf("Failed to remove directory %s: %v", d.Path, err)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to remove %d file(s): %v", len(failed), failed)
+	}
+	return nil
+}
@@ -0,0 +1,577 @@
+/*
+  Blink, a powerful source-based package manager. Core of ApertureOS.
+	Want to use it for your own project?
+	Blink is completely FOSS (Free and Open Source),
+	edit, publish, use, contribute to Blink however you prefer.
+  Copyright (C) 2025-2026 Aperture OS
+
+  This program is free software: you can redistribute it and/or modify
+  it under the terms of the Apache 2.0 License as published by
+  the Apache Software Foundation, either version 2.0 of the License, or
+  any later version.
+
+  This program is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <https://www.apache.org/licenses/LICENSE-2.0>.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/Aperture-OS/eyes"
+)
+
+/****************************************************/
+// Generations: after every transaction that changed something, the
+// manifest and the recipe of every installed package are snapshotted
+// under a new number. "blink rollback 12" works out what to remove,
+// install, upgrade or downgrade to get back to generation 12 and does
+// it in one transaction, which then becomes the newest generation.
+// only the newest keepGenerations are kept, older ones get pruned
+//
+// generationsPath/<n>/
+//   generation.json    number, date, what created it
+//   manifest.toml      the manifest right after that transaction
+//   recipes/<pkg>.json the recipe each installed package was built from
+/****************************************************/
+
+// Generation is generation.json
+type Generation struct {
+	Number   int       `json:"number"`
+	Created  time.Time `json:"created"`
+	Kind     string    `json:"kind"`     // transaction that created it (install, update, rollback...)
+	Packages []string  `json:"packages"` // what the transaction was asked for
+}
+
+// generationDir is generationsPath/<n>
+func generationDir(n int) string {
+	return filepath.Join(generationsPath, strconv.Itoa(n))
+}
+
+/****************************************************/
+// listGenerations returns every generation, oldest first
+/****************************************************/
+func listGenerations() ([]Generation, error) {
+	entries, err := os.ReadDir(generationsPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var gens []Generation
+	for _, e := range entries {
+		n, err := strconv.Atoi(e.Name())
+		if err != nil || !e.IsDir() {
+			continue // leftovers of an interrupted snapshot
+		}
+
+		data, err := os.ReadFile(filepath.Join(generationDir(n), "generation.json"))
+		if err != nil {
+			eyes.Warnf("Generation %d is incomplete, skipping: %v", n, err)
+			continue
+		}
+
+		var g Generation
+		if err := json.Unmarshal(data, &g); err != nil {
+			eyes.Warnf("Generation %d is invalid, skipping: %v", n, err)
+			continue
+		}
+		gens = append(gens, g)
+	}
+
+	sort.Slice(gens, func(i, j int) bool { return gens[i].Number < gens[j].Number })
+	return gens, nil
+}
+
+/****************************************************/
+// loadGeneration reads the manifest of generation n
+/****************************************************/
+func loadGeneration(n int) (Manifest, error) {
+	var m Manifest
+	if _, err := toml.DecodeFile(filepath.Join(generationDir(n), "manifest.toml"), &m); err != nil {
+		if os.IsNotExist(err) {
+			return m, fmt.Errorf("generation %d does not exist", n)
+		}
+		return m, fmt.Errorf("failed to read generation %d: %v", n, err)
+	}
+	return m, nil
+}
+
+/****************************************************/
+// generationRecipe reads the recipe snapshot of a package in generation n
+/****************************************************/
+func generationRecipe(n int, pkgName string) (PackageInfo, []byte, error) {
+	data, err := os.ReadFile(filepath.Join(generationDir(n), "recipes", pkgName+".json"))
+	if err != nil {
+		return PackageInfo{}, nil, fmt.Errorf("generation %d has no recipe for %s: %v", n, pkgName, err)
+	}
+
+	var pkg PackageInfo
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return PackageInfo{}, nil, fmt.Errorf("generation %d has an invalid recipe for %s: %v", n, pkgName, err)
+	}
+	return pkg, data, nil
+}
+
+/****************************************************/
+// snapshotRecipe returns the recipe an installed package was built
+// from: the one recorded in the package database, else (packages
+// installed before recipes were recorded) the previous generation's
+// or the default recipes cache's if it has that version. nil when
+// none does
+/****************************************************/
+func snapshotRecipe(prev int, inst InstalledPkg) []byte {
+	if data, err := os.ReadFile(recipeDBPath(inst.Name)); err == nil {
+		return data
+	}
+
+	candidates := []string{filepath.Join(recipePath, inst.Name+".json")}
+	if prev > 0 {
+		candidates = append([]string{filepath.Join(generationDir(prev), "recipes", inst.Name+".json")}, candidates...)
+	}
+
+	for _, file := range candidates {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		var pkg PackageInfo
+		if err := json.Unmarshal(data, &pkg); err != nil {
+			continue
+		}
+		if compareEVR(pkg.evr(), inst.evr()) == 0 {
+			return data
+		}
+	}
+	return nil
+}
+
+/****************************************************/
+// recordGeneration snapshots the current manifest and recipes as a new
+// generation, unless the manifest is exactly the newest generation's
+/****************************************************/
+func recordGeneration(kind string, pkgs []string) error {
+	manifest, err := os.ReadFile(manifestPath)
+	if os.IsNotExist(err) {
+		return nil // nothing installed, ever
+	}
+	if err != nil {
+		return err
+	}
+
+	gens, err := listGenerations()
+	if err != nil {
+		return err
+	}
+
+	prev := 0
+	if len(gens) > 0 {
+		prev = gens[len(gens)-1].Number
+		last, err := os.ReadFile(filepath.Join(generationDir(prev), "manifest.toml"))
+		if err == nil && bytes.Equal(last, manifest) {
+			return nil
+		}
+	}
+
+	db, err := packageDB()
+	if err != nil {
+		return err
+	}
+
+	// build it next to the others, rename when complete
+	n := prev + 1
+	tmp := generationDir(n) + ".tmp"
+	_ = os.RemoveAll(tmp)
+	if err := os.MkdirAll(filepath.Join(tmp, "recipes"), 0755); err != nil {
+		return err
+	}
+
+	for _, inst := range db.Installed {
+		data := snapshotRecipe(prev, inst)
+		if data == nil {
+			eyes.Warnf("No recipe for %s %s to snapshot, generation %d won't be able to reinstall it", inst.Name, inst.evr(), n)
+			continue
+		}
+		if err := writeFileSync(filepath.Join(tmp, "recipes", inst.Name+".json"), data); err != nil {
+			return err
+		}
+	}
+
+	if err := writeFileSync(filepath.Join(tmp, "manifest.toml"), manifest); err != nil {
+		return err
+	}
+
+	info, err := json.MarshalIndent(Generation{
+		Number:   n,
+		Created:  time.Now(),
+		Kind:     kind,
+		Packages: pkgs,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileSync(filepath.Join(tmp, "generation.json"), info); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, generationDir(n)); err != nil {
+		return err
+	}
+
+	eyes.Infof("Recorded generation %d", n)
+
+	if err := pruneGenerations(keepGenerations); err != nil {
+		eyes.Warnf("%v", err)
+	}
+	return nil
+}
+
+/****************************************************/
+// pruneGenerations deletes the oldest generations until only keep
+// are left, the newest one (what's installed now) always stays
+/****************************************************/
+func pruneGenerations(keep int) error {
+	if keep < 1 {
+		keep = 1
+	}
+
+	gens, err := listGenerations()
+	if err != nil {
+		return err
+	}
+
+	for len(gens) > keep {
+		if err := os.RemoveAll(generationDir(gens[0].Number)); err != nil {
+			return fmt.Errorf("failed to prune generation %d: %v", gens[0].Number, err)
+		}
+		eyes.Infof("Pruned generation %d", gens[0].Number)
+		gens = gens[1:]
+	}
+	return nil
+}
+
+/****************************************************/
+// showGenerations prints every generation, newest last, the current
+// one (the newest) is marked
+/****************************************************/
+func showGenerations(asJSON bool) error {
+	gens, err := listGenerations()
+	if err != nil {
+		return err
+	}
+
+	if asJSON {
+		if gens == nil {
+			gens = []Generation{}
+		}
+		return printJSON(gens)
+	}
+
+	if len(gens) == 0 {
+		eyes.Infof("No generations recorded yet, the first transaction creates one.")
+		return nil
+	}
+
+	for i, g := range gens {
+		m, err := loadGeneration(g.Number)
+		count := len(m.Installed)
+		if err != nil {
+			count = -1
+		}
+
+		current := ""
+		if i == len(gens)-1 {
+			current = "  (current)"
+		}
+
+		what := g.Kind
+		if len(g.Packages) > 0 {
+			what += " " + strings.Join(g.Packages, " ")
+		}
+
+		fmt.Printf("%4d  %s  %3d packages  %s%s\n", g.Number, g.Created.Format("2006-01-02 15:04"), count, what, current)
+	}
+
+	return nil
+}
+
+// rollbackStep is one change of a rollback plan
+type rollbackStep struct {
+	Name      string
+	From      string // installed version, empty for installs
+	To        string // target version, empty for removals
+	Downgrade bool
+}
+
+/****************************************************/
+// planRollback compares the current manifest against generation n's
+// and returns what to remove and what to (re)install
+/****************************************************/
+func planRollback(current Manifest, target Manifest) ([]rollbackStep, []rollbackStep) {
+	want := make(map[string]InstalledPkg, len(target.Installed))
+	for _, inst := range target.Installed {
+		want[inst.Name] = inst
+	}
+	have := make(map[string]InstalledPkg, len(current.Installed))
+	for _, inst := range current.Installed {
+		have[inst.Name] = inst
+	}
+
+	var remove, install []rollbackStep
+	for _, inst := range current.Installed {
+		if _, ok := want[inst.Name]; !ok {
+			remove = append(remove, rollbackStep{Name: inst.Name, From: inst.evr().String()})
+		}
+	}
+	for _, inst := range target.Installed {
+		old, ok := have[inst.Name]
+		if !ok {
+			install = append(install, rollbackStep{Name: inst.Name, To: inst.evr().String()})
+			continue
+		}
+		if compareEVR(old.evr(), inst.evr()) != 0 {
+			install = append(install, rollbackStep{
+				Name:      inst.Name,
+				From:      old.evr().String(),
+				To:        inst.evr().String(),
+				Downgrade: compareEVR(inst.evr(), old.evr()) < 0,
+			})
+		}
+	}
+
+	sort.Slice(remove, func(i, j int) bool { return remove[i].Name < remove[j].Name })
+	sort.Slice(install, func(i, j int) bool { return install[i].Name < install[j].Name })
+	return remove, install
+}
+
+/****************************************************/
+// rollbackTo brings the system back to generation n (0 = the one
+// before the current one). the packages are installed from the
+// generation's recipe snapshot, dependencies first, with the install
+// reasons and optional dependency choices that generation had
+/****************************************************/
+func rollbackTo(n int, path string) error {
+	if err := ensureManifest(); err != nil {
+		return err
+	}
+
+	gens, err := listGenerations()
+	if err != nil {
+		return err
+	}
+	if len(gens) == 0 {
+		return fmt.Errorf("no generations recorded yet")
+	}
+
+	if n == 0 {
+		if len(gens) < 2 {
+			return fmt.Errorf("there is no generation before the current one (%d)", gens[0].Number)
+		}
+		n = gens[len(gens)-2].Number
+	}
+
+	target, err := loadGeneration(n)
+	if err != nil {
+		return err
+	}
+
+	db, err := packageDB()
+	if err != nil {
+		return err
+	}
+	current := db.Manifest
+
+	remove, reinstall := planRollback(current, target)
+	if len(remove) == 0 && len(reinstall) == 0 {
+		eyes.Successf("Installed packages already match generation %d.", n)
+		return nil
+	}
+
+	// a hold says "don't touch", rollback doesn't get to override it
+	for _, steps := range [][]rollbackStep{remove, reinstall} {
+		for _, s := range steps {
+			if hold := findHold(current, s.Name); hold != nil && s.From != "" {
+				return fmt.Errorf("package %s is held (%s), unhold it to roll back", s.Name, hold)
+			}
+		}
+	}
+
+	// every recipe has to be there before anything gets touched
+	recipes := make(map[string][]byte, len(reinstall))
+	for _, s := range reinstall {
+		_, data, err := generationRecipe(n, s.Name)
+		if err != nil {
+			return err
+		}
+		recipes[s.Name] = data
+	}
+
+	eyes.Warnf("Rolling back to generation %d:", n)
+	for _, s := range remove {
+		fmt.Printf(" - remove    %s %s\n", s.Name, s.From)
+	}
+	for _, s := range reinstall {
+		switch {
+		case s.From == "":
+			fmt.Printf(" - install   %s %s\n", s.Name, s.To)
+		case s.Downgrade:
+			fmt.Printf(" - downgrade %s %s → %s\n", s.Name, s.From, s.To)
+		default:
+			fmt.Printf(" - upgrade   %s %s → %s\n", s.Name, s.From, s.To)
+		}
+	}
+
+	eyes.Warn("Proceed with rollback? [ (Y)es / (N)o ]: ")
+	var input string
+	fmt.Scanln(&input)
+
+	if normalizeYesNo(input) == "no" {
+		eyes.Infof("Rollback aborted by user.")
+		return nil
+	}
+
+	return withTransaction("rollback", []string{strconv.Itoa(n)}, func() error {
+		return applyRollback(current, target, remove, reinstall, recipes, path)
+	})
+}
+
+/****************************************************/
+// applyRollback runs a rollback plan, inside the rollback transaction
+/****************************************************/
+func applyRollback(
+	current Manifest,
+	target Manifest,
+	remove []rollbackStep,
+	reinstall []rollbackStep,
+	recipes map[string][]byte,
+	path string,
+) error {
+	// removals first, dependents before their dependencies
+	if len(remove) > 0 {
+		graph, err := installedDepGraph(current, path, true)
+		if err != nil {
+			return err
+		}
+
+		set := make(map[string]bool, len(remove))
+		for _, s := range remove {
+			set[s.Name] = true
+		}
+
+		order, err := removalOrder(graph, set)
+		if err != nil {
+			return err
+		}
+
+		for _, name := range order {
+			eyes.Infof("Removing %s", name)
+			if err := uninstall(name, false, path); err != nil {
+				return fmt.Errorf("failed to uninstall %s: %v", name, err)
+			}
+		}
+	}
+
+	// the generation's recipes win over the repositories' while this
+	// runs, dependency resolution included (see presetRecipes), and the
+	// packages the rollback leaves alone keep their installed recipe. so
+	// dependencies are checked against the generation, never against
+	// wherever the repositories moved since. the recipes cache isn't touched
+	presetRecipes = make(map[string]PackageInfo, len(target.Installed))
+	defer func() { presetRecipes = nil }()
+	for name, data := range recipes {
+		var pkg PackageInfo
+		if err := json.Unmarshal(data, &pkg); err != nil {
+			return fmt.Errorf("invalid recipe for %s: %v", name, err)
+		}
+		presetRecipes[name] = pkg
+	}
+	for _, inst := range target.Installed {
+		if _, ok := presetRecipes[inst.Name]; ok {
+			continue
+		}
+		pkg, err := installedRecipe(path, inst.Name)
+		if err != nil {
+			return fmt.Errorf("no recipe for %s: %v", inst.Name, err)
+		}
+		presetRecipes[inst.Name] = pkg
+	}
+
+	// the generation's dependencies, not the ones of what's installed now
+	graph, err := manifestDepGraph(target, true, func(name string) (PackageInfo, error) {
+		if pkg, ok := presetRecipes[name]; ok {
+			return pkg, nil
+		}
+		return installedRecipe(path, name) // unchanged, already the right one
+	})
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(target.Installed))
+	wanted := make(map[string]InstalledPkg, len(target.Installed))
+	for _, inst := range target.Installed {
+		names = append(names, inst.Name)
+		wanted[inst.Name] = inst
+	}
+	sort.Strings(names)
+
+	if err := graph.DFS(names); err != nil {
+		return fmt.Errorf("dependency cycle detected: %v", err)
+	}
+
+	todo := make(map[string]bool, len(reinstall))
+	for _, s := range reinstall {
+		todo[s.Name] = true
+	}
+
+	// the generation already made the optional dependency choices
+	presetOptDeps = make(map[string][]OptSelection)
+	defer func() { presetOptDeps = nil }()
+
+	// TopoSort = dependencies first
+	for _, name := range graph.TopoSort() {
+		if !todo[name] {
+			continue
+		}
+
+		pkg, ok := presetRecipes[name]
+		if !ok {
+			return fmt.Errorf("generation has no recipe for %s", name)
+		}
+
+		presetOptDeps[name] = wanted[name].OptDeps
+		eyes.Infof("Installing %s %s", name, pkg.evr())
+		if err := installRecipe(pkg, true, path, wanted[name].Reason); err != nil {
+			return fmt.Errorf("failed to install %s: %v", name, err)
+		}
+	}
+
+	// addToManifest only ever promotes the reason, put the old ones back
+	db, err := packageDB()
+	if err != nil {
+		return err
+	}
+	for name, inst := range wanted {
+		if p, ok := db.Get(name); ok && inst.Reason != "" {
+			p.Reason = inst.Reason
+		}
+	}
+	return db.Save()
+}
@@ -22,6 +22,7 @@ package main
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
 	"time"
 )
@@ -37,23 +38,38 @@ import (
 var (
 	distroName = "ApertureOS"
 
-	defaultCachePath = "/home/elia/Desktop/ApertureOS/blink/var-blink"      // Default: /var/blink/
-	currentYear      = time.Now().Year()                                    // Current year for copyright
-	Version          = "v0.1.0-alpha"                                       // Blink version
-	lockPath         = filepath.Join(defaultCachePath, "etc", "blink.lock") // Path to lock file
+	defaultCachePath = "/home/elia/Desktop/ApertureOS/blink/var-blink" // Default: /var/blink/
+	currentYear      = time.Now().Year()                               // Current year for copyright
+	Version          = "v0.1.0-alpha"                                  // Blink version
+
+	installRoot = "/" // Target filesystem, changed with --root for images and chroots
+
+	keepGenerations = 50 // Generations kept, recordGeneration prunes older ones
+
+	httpRepoTimeout = 2 * time.Minute // Per request, for the files of http repositories
 
-	configPath        = filepath.Join(defaultCachePath, "etc", "config.toml")
 	defaultRepoConfig = `
 [pseudoRepository]
 git_url = "https://github.com/Aperture-OS/testing-blink-repo.git"
 branch = "main"
 `
 
-	repoCachePath = filepath.Join(defaultCachePath, "repositories")
-	sourcePath    = filepath.Join(defaultCachePath, "sources") // Path to downloaded source
-	recipePath    = filepath.Join(defaultCachePath, "recipes")
-	manifestPath  = filepath.Join(defaultCachePath, "etc", "manifest.toml")
-	buildRoot     = filepath.Join(defaultCachePath, "build")
+	// everything below lives inside defaultCachePath, see setPaths
+	lockPath        string // Path to lock file
+	configPath      string
+	repoCachePath   string
+	sourcePath      string // Path to downloaded source
+	recipePath      string
+	manifestPath    string
+	pkgDBPath       string // per-package file database, one dir per package
+	buildRoot       string
+	stageRoot       string // DESTDIR for builds, one dir per package
+	journalPath     string // transaction journal, only exists while a transaction runs
+	generationsPath string // numbered snapshots of the manifest and recipes
+	trustedKeysPath string // public keys repositories may be signed with
+	repoLockPath    string // revisions written by sync --lock, read by sync --frozen
+
+	overwriteGlobs []string // --overwrite patterns, conflicting files matching them may be overwritten
 
 	supportPage = // Support information string
 	`Having trouble? Join our Discord Server or open a GitHub issue.
@@ -68,3 +84,58 @@ branch = "main"
 	All rights reserved. © Copyright 2025-%d Aperture OS.
 	`, Version, currentYear)  // return the formatted string
 ) // TODO: migrate to /var/blink
+
+func init() {
+	setPaths()
+}
+
+/****************************************************/
+// setPaths (re)computes every path that lives inside defaultCachePath
+// one place, so --root can't forget to move one of them
+/****************************************************/
+func setPaths() {
+	lockPath = filepath.Join(defaultCachePath, "etc", "blink.lock")
+	configPath = filepath.Join(defaultCachePath, "etc", "config.toml")
+	repoCachePath = filepath.Join(defaultCachePath, "repositories")
+	sourcePath = filepath.Join(defaultCachePath, "sources")
+	recipePath = filepath.Join(defaultCachePath, "recipes")
+	manifestPath = filepath.Join(defaultCachePath, "etc", "manifest.toml")
+	pkgDBPath = filepath.Join(defaultCachePath, "etc", "packages")
+	buildRoot = filepath.Join(defaultCachePath, "build")
+	stageRoot = filepath.Join(defaultCachePath, "stage")
+	journalPath = filepath.Join(defaultCachePath, "journal")
+	generationsPath = filepath.Join(defaultCachePath, "etc", "generations")
+	trustedKeysPath = filepath.Join(defaultCachePath, "etc", "trusted_keys")
+	repoLockPath = filepath.Join(defaultCachePath, "etc", "repos.lock")
+}
+
+/****************************************************/
+// setRoot relocates Blink into another root filesystem (--root):
+// files get merged under root, and the manifest, lock, cache and
+// package database move to root + defaultCachePath, so the image
+// carries its own package database
+/****************************************************/
+func setRoot(root string) error {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(abs)
+	if err != nil {
+		return fmt.Errorf("invalid root %s: %v", root, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("invalid root %s: not a directory", root)
+	}
+
+	if abs == "/" {
+		return nil
+	}
+
+	installRoot = abs
+	defaultCachePath = filepath.Join(abs, defaultCachePath)
+	setPaths()
+
+	return nil
+}
@@ -0,0 +1,332 @@
+/*
+  Blink, a powerful source-based package manager. Core of ApertureOS.
+	Want to use it for your own project?
+	Blink is completely FOSS (Free and Open Source),
+	edit, publish, use, contribute to Blink however you prefer.
+  Copyright (C) 2025-2026 Aperture OS
+
+  This program is free software: you can redistribute it and/or modify
+  it under the terms of the Apache 2.0 License as published by
+  the Apache Software Foundation, either version 2.0 of the License, or
+  any later version.
+
+  This program is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <https://www.apache.org/licenses/LICENSE-2.0>.
+*/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/Aperture-OS/eyes"
+)
+
+/****************************************************/
+// Versioned installs: "blink install gcc@13.2.0" digs the recipe of
+// that version out of the repository's git history and installs it
+// like any other recipe, fetchpkg hands it out instead of the
+// repositories' while that runs (see presetRecipes). that's also how
+// you downgrade, addToManifest notices the version went backwards and
+// marks the package as downgraded
+/****************************************************/
+
+// historicRecipe is a recipe found in a repository's history
+type historicRecipe struct {
+	Pkg    PackageInfo
+	Repo   string
+	Commit string
+}
+
+/****************************************************/
+// splitVersionSpec splits "pkg@version", version is empty without "@"
+/****************************************************/
+func splitVersionSpec(spec string) (string, string, error) {
+	name, version, found := strings.Cut(spec, "@")
+	name = strings.TrimSpace(name)
+	version = strings.TrimSpace(version)
+
+	if name == "" || (found && version == "") {
+		return "", "", fmt.Errorf("invalid package %q, expected <pkg> or <pkg>@<version>", spec)
+	}
+	return name, version, nil
+}
+
+/****************************************************/
+// findRecipeVersion walks the git history of <pkg>.json in every
+// configured repository (resolution order, newest commit first) and
+// returns the first recipe whose version matches. version can be
+// "1.2.3", "1.2.3-2" or "1:1.2.3-2", a missing release matches any.
+// spec can be "repo/pkg" to only look at that repository
+/****************************************************/
+func findRecipeVersion(spec string, version string) (historicRecipe, error) {
+	want, err := parseConstraints("=" + version)
+	if err != nil {
+		return historicRecipe{}, fmt.Errorf("invalid version %q: %v", version, err)
+	}
+
+	repos, err := LoadRepos(configPath)
+	if err != nil {
+		return historicRecipe{}, err
+	}
+
+	repoName, pkgName := splitRepoSpec(spec)
+	if !recipeNameRe.MatchString(pkgName) {
+		return historicRecipe{}, fmt.Errorf("invalid package %q, expected <pkg> or <repo>/<pkg>", spec)
+	}
+	if repoName != "" {
+		repo, ok := FindRepoByName(repoName, repos)
+		if !ok {
+			return historicRecipe{}, fmt.Errorf("repository %s is not configured", repoName)
+		}
+		if repo.Disabled {
+			return historicRecipe{}, fmt.Errorf("repository %s is disabled, run 'blink repo enable %s' first", repoName, repoName)
+		}
+	}
+
+	var candidates []RepoConfig
+	for _, repo := range sortedRepos(repos) {
+		if repo.Disabled || (repoName != "" && repo.Name != repoName) {
+			continue
+		}
+		// http and local repositories only have what they have now
+		if repo.Type == repoTypeHTTP || repo.Type == repoTypeLocal {
+			if repoName != "" {
+				return historicRecipe{}, fmt.Errorf("repository %s (%s) keeps no history", repoName, repo.Type)
+			}
+			continue
+		}
+		candidates = append(candidates, repo)
+	}
+
+	var seen []string
+	for _, repo := range candidates {
+		name := repo.Name
+		repoPath := repoDir(repo)
+
+		out, err := exec.Command("git", "-C", repoPath, "log", "--format=%H", "--", pkgName+".json").Output()
+		if err != nil {
+			eyes.Warnf("Could not read the history of repository %s: %v", name, err)
+			continue
+		}
+
+		scanner := bufio.NewScanner(bytes.NewReader(out))
+		for scanner.Scan() {
+			commit := strings.TrimSpace(scanner.Text())
+			if commit == "" {
+				continue
+			}
+
+			// the commit that deleted the file has nothing to show
+			data, err := exec.Command("git", "-C", repoPath, "show", commit+":"+pkgName+".json").Output()
+			if err != nil {
+				continue
+			}
+
+			var pkg PackageInfo
+			if err := json.Unmarshal(data, &pkg); err != nil {
+				eyes.Warnf("Skipping unreadable %s.json at %s/%s: %v", pkgName, name, commit[:12], err)
+				continue
+			}
+
+			if want.satisfiedBy(pkg.evr()) {
+				if err := verifyHistoricRecipe(repo, commit, pkgName+".json", data); err != nil {
+					return historicRecipe{}, fmt.Errorf("%s at %s/%s: %v", pkgName, name, commit[:12], err)
+				}
+				return historicRecipe{Pkg: pkg, Repo: name, Commit: commit}, nil
+			}
+
+			if v := pkg.evr().String(); !containsString(seen, v) {
+				seen = append(seen, v)
+			}
+		}
+	}
+
+	if len(seen) == 0 {
+		return historicRecipe{}, fmt.Errorf("package %s not found in any repository history", pkgName)
+	}
+	return historicRecipe{}, fmt.Errorf("version %s of %s not found, known versions: %s", version, pkgName, strings.Join(seen, ", "))
+}
+
+/****************************************************/
+// verifyHistoricRecipe checks an old recipe against the signed index
+// of the same commit, the same way readRecipe does for the current one.
+// a recipe that was only signed by a later commit (git log only lists
+// commits that touched the recipe) is fine if the current index still
+// vouches for the same bytes
+/****************************************************/
+func verifyHistoricRecipe(repo RepoConfig, commit, rel string, data []byte) error {
+	err := verifyRecipeAt(repo, commit, rel, data)
+	if err == nil || commit == "HEAD" {
+		return err
+	}
+	if verifyRecipeAt(repo, "HEAD", rel, data) == nil {
+		return nil
+	}
+	return err
+}
+
+// verifyRecipeAt checks data against the signed index of one commit,
+// unsigned commits pass unless the repository is marked signed
+func verifyRecipeAt(repo RepoConfig, commit, rel string, data []byte) error {
+	repoPath := repoDir(repo)
+
+	sigData, err := exec.Command("git", "-C", repoPath, "show", commit+":"+repoSigFile).Output()
+	if err != nil {
+		if repo.Signed {
+			return fmt.Errorf("repository is marked signed but this commit has no %s", repoSigFile)
+		}
+		return nil
+	}
+
+	indexData, err := exec.Command("git", "-C", repoPath, "show", commit+":"+repoIndexFile).Output()
+	if err != nil {
+		return fmt.Errorf("has %s but no %s", repoSigFile, repoIndexFile)
+	}
+
+	index, _, err := verifyIndexData(indexData, sigData)
+	if err != nil {
+		return err
+	}
+	return checkRecipeHash(index, rel, data)
+}
+
+/****************************************************/
+// brokenDependents returns, for every installed package that depends
+// on pkgName with a constraint the given version doesn't satisfy,
+// a "dependent (requires constraint)" line
+/****************************************************/
+func brokenDependents(path string, pkgName string, v pkgVersion) ([]string, error) {
+	db, err := packageDB()
+	if err != nil {
+		return nil, err
+	}
+	m := db.Manifest
+
+	var broken []string
+	for _, inst := range m.Installed {
+		if inst.Name == pkgName {
+			continue
+		}
+
+		recipe, err := installedRecipe(path, inst.Name)
+		if err != nil {
+			eyes.Warnf("Could not read recipe of %s, not checking its constraints: %v", inst.Name, err)
+			continue
+		}
+
+		constraint, ok := recipe.Dependencies[pkgName]
+		if !ok {
+			continue
+		}
+
+		set, err := parseConstraints(constraint)
+		if err != nil {
+			eyes.Warnf("Invalid version constraint for dependency %s of %s: %v", pkgName, inst.Name, err)
+			continue
+		}
+
+		if !set.satisfiedBy(v) {
+			broken = append(broken, fmt.Sprintf("%s (requires %s %s)", inst.Name, pkgName, set))
+		}
+	}
+
+	sort.Strings(broken)
+	return broken, nil
+}
+
+/****************************************************/
+// installVersion installs one specific version of a package, the
+// spec is "pkg@version" or "repo/pkg@version". installed dependents whose constraints the
+// version breaks are listed and the user has to confirm
+/****************************************************/
+func installVersion(spec string, force bool, path string) error {
+	pkgSpec, version, err := splitVersionSpec(spec)
+	if err != nil {
+		return err
+	}
+	_, pkgName := splitRepoSpec(pkgSpec)
+
+	if err := ensureManifest(); err != nil {
+		return err
+	}
+
+	// history lives in the clones, make sure they're there
+	if err := ensureRepo(false); err != nil {
+		return fmt.Errorf("failed to update repository: %v", err)
+	}
+
+	found, err := findRecipeVersion(pkgSpec, version)
+	if err != nil {
+		return err
+	}
+	pkg := found.Pkg
+	eyes.Infof("Found %s %s in repository %s at commit %s", pkg.Name, pkg.evr(), found.Repo, found.Commit)
+
+	installed, exists, err := manifestHas(pkgName)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		switch cmp := compareEVR(pkg.evr(), installed.evr()); {
+		case cmp == 0 && !force:
+			eyes.Errorf("Package %s is already installed at %s. Use --force to reinstall.", pkgName, installed.evr())
+			return fmt.Errorf("package %s already installed (%s)", pkgName, installed.evr())
+		case cmp < 0:
+			eyes.Warnf("Downgrading %s: %s → %s", pkgName, installed.evr(), pkg.evr())
+		case cmp > 0:
+			eyes.Infof("Upgrading %s: %s → %s", pkgName, installed.evr(), pkg.evr())
+		}
+	}
+
+	broken, err := brokenDependents(path, pkgName, pkg.evr())
+	if err != nil {
+		return err
+	}
+	if len(broken) > 0 {
+		eyes.Warnf("%s %s does not satisfy these installed packages:", pkgName, pkg.evr())
+		for _, b := range broken {
+			fmt.Printf(" - %s\n", b)
+		}
+
+		eyes.Warn("Install it anyway? [ (Y)es / (N)o ]: ")
+		var input string
+		fmt.Scanln(&input)
+
+		if normalizeYesNo(input) != "yes" {
+			return fmt.Errorf("installation of %s %s aborted by user", pkgName, pkg.evr())
+		}
+	}
+
+	// dependency resolution goes through fetchpkg, hand it the old
+	// recipe there instead of writing it into the recipes cache
+	presetRecipes = map[string]PackageInfo{pkgName: pkg}
+	defer func() { presetRecipes = nil }()
+
+	if err := installRecipe(pkg, true, path, reasonExplicit); err != nil {
+		return err
+	}
+
+	// addToManifest recorded the repository HEAD, this came from further back
+	if err := setManifestOrigin(pkgName, found.Repo, found.Commit); err != nil {
+		return err
+	}
+
+	if exists && compareEVR(pkg.evr(), installed.evr()) < 0 {
+		eyes.Successf("Downgraded %s to %s. blink update will upgrade it again, use \"blink hold %s\" to keep it.", pkgName, pkg.evr(), pkgName)
+	} else {
+		eyes.Successf("Installed %s %s.", pkgName, pkg.evr())
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+/*
+  Blink, a powerful source-based package manager. Core of ApertureOS.
+	Want to use it for your own project?
+	Blink is completely FOSS (Free and Open Source),
+	edit, publish, use, contribute to Blink however you prefer.
+  Copyright (C) 2025-2026 Aperture OS
+
+  This program is free software: you can redistribute it and/or modify
+  it under the terms of the Apache 2.0 License as published by
+  the Apache Software Foundation, either version 2.0 of the License, or
+  any later version.
+
+  This program is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <https://www.apache.org/licenses/LICENSE-2.0>.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Aperture-OS/eyes"
+)
+
+/****************************************************/
+// Holds: "blink hold gcc" keeps gcc exactly where it is while
+// "blink update" moves everything else, "blink hold gcc=13.2.0" pins it
+// to that version. holds live in the manifest next to the installed
+// packages. anything that would change a held package (update, dependency
+// upgrade, reinstall) stops and says which hold is in the way
+/****************************************************/
+
+// findHold returns the hold on a package, nil if it isn't held
+func findHold(m Manifest, name string) *Hold {
+	for i := range m.Holds {
+		if m.Holds[i].Name == name {
+			return &m.Holds[i]
+		}
+	}
+	return nil
+}
+
+// String is "name" or "name=version", same syntax as the hold command
+func (h Hold) String() string {
+	if h.Version == "" {
+		return h.Name
+	}
+	return h.Name + "=" + h.Version
+}
+
+// pins tells whether h is a pin that v satisfies, compared as
+// epoch:version-release like a "=" constraint, so a pin without a
+// release matches every release of that version
+func (h Hold) pins(v pkgVersion) bool {
+	if h.Version == "" {
+		return false
+	}
+	set, err := parseConstraints("=" + h.Version)
+	return err == nil && set.satisfiedBy(v)
+}
+
+/****************************************************/
+// holdPackage adds or replaces a hold, spec is "pkg" or "pkg=version"
+/****************************************************/
+func holdPackage(spec string) error {
+	name, version, _ := strings.Cut(spec, "=")
+	name = strings.TrimSpace(name)
+	version = strings.TrimSpace(version)
+
+	if name == "" {
+		return fmt.Errorf("invalid hold %q, expected <pkg> or <pkg>=<version>", spec)
+	}
+	if version != "" {
+		if _, _, err := parseEVR(version); err != nil {
+			return fmt.Errorf("invalid hold %q: %v", spec, err)
+		}
+	}
+	hold := Hold{Name: name, Version: version, Since: time.Now()}
+
+	if err := ensureManifest(); err != nil {
+		return err
+	}
+
+	db, err := packageDB()
+	if err != nil {
+		return err
+	}
+
+	installed := false
+	for _, inst := range db.Installed {
+		if inst.Name == name {
+			installed = true
+			if version == "" {
+				eyes.Infof("Holding %s at installed version %s", name, inst.evr())
+			} else if !hold.pins(inst.evr()) {
+				eyes.Warnf("%s is installed at %s, pinning it to %s anyway", name, inst.evr(), version)
+			}
+		}
+	}
+
+	// a bare hold on something not installed would hold nothing
+	if !installed && version == "" {
+		return fmt.Errorf("%s is not installed, use %s=<version> to pin a version before installing it", name, name)
+	}
+
+	if existing := findHold(db.Manifest, name); existing != nil {
+		*existing = hold
+	} else {
+		db.Holds = append(db.Holds, hold)
+	}
+
+	if err := db.Save(); err != nil {
+		return err
+	}
+
+	eyes.Successf("%s is now held.", hold)
+	return nil
+}
+
+/****************************************************/
+// unholdPackage removes the hold on a package
+/****************************************************/
+func unholdPackage(name string) error {
+	db, err := packageDB()
+	if err != nil {
+		return err
+	}
+
+	kept := make([]Hold, 0, len(db.Holds))
+	found := false
+	for _, h := range db.Holds {
+		if h.Name == name {
+			found = true
+			continue
+		}
+		kept = append(kept, h)
+	}
+
+	if !found {
+		return fmt.Errorf("%s is not held", name)
+	}
+
+	db.Holds = kept
+	if err := db.Save(); err != nil {
+		return err
+	}
+
+	eyes.Successf("%s is no longer held.", name)
+	return nil
+}
+
+/****************************************************/
+// checkHold tells whether installing pkg (the recipe about to be
+// installed) is allowed by the holds. a pin allows exactly its version,
+// a plain hold allows only reinstalling the version already installed
+/****************************************************/
+func checkHold(m Manifest, pkg PackageInfo) error {
+	hold := findHold(m, pkg.Name)
+	if hold == nil {
+		return nil
+	}
+
+	if hold.Version != "" {
+		if !hold.pins(pkg.evr()) {
+			return fmt.Errorf("%s is pinned to version %s (repository has %s), run 'blink unhold %s' first",
+				pkg.Name, hold.Version, pkg.evr(), pkg.Name)
+		}
+		return nil
+	}
+
+	for _, inst := range m.Installed {
+		if inst.Name == pkg.Name && compareEVR(inst.evr(), pkg.evr()) != 0 {
+			return fmt.Errorf("%s is held at %s (repository has %s), run 'blink unhold %s' first",
+				pkg.Name, inst.evr(), pkg.evr(), pkg.Name)
+		}
+	}
+
+	return nil
+}
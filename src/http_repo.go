@@ -0,0 +1,441 @@
+/*
+  Blink, a powerful source-based package manager. Core of ApertureOS.
+	Want to use it for your own project?
+	Blink is completely FOSS (Free and Open Source),
+	edit, publish, use, contribute to Blink however you prefer.
+  Copyright (C) 2025-2026 Aperture OS
+
+  This program is free software: you can redistribute it and/or modify
+  it under the terms of the Apache 2.0 License as published by
+  the Apache Software Foundation, either version 2.0 of the License, or
+  any later version.
+
+  This program is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <https://www.apache.org/licenses/LICENSE-2.0>.
+*/
+
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/Aperture-OS/eyes"
+)
+
+/****************************************************/
+// HTTP repositories: type = "http" in config.toml, for systems without
+// git. the repository is a few static files under url:
+//
+//   INDEX.gz              gzipped INDEX (the RepoIndex, see index.go)
+//   INDEX.<id>.sig        signature of the uncompressed INDEX (optional)
+//   recipes.<id>.tar.gz   every recipe, at the paths INDEX lists
+//
+// <id> is the sha256 of the uncompressed INDEX, so the signature and
+// tarball of every index have their own names and replacing INDEX.gz
+// switches to the new ones all at once, a client never pairs an index
+// with the signature or recipes of another
+//
+// sync asks for INDEX.gz with If-None-Match / If-Modified-Since and stops
+// there on a 304. otherwise it unpacks everything into a staging dir,
+// checks it like a git clone (signature, recipe hashes) and swaps it in,
+// so repoCachePath/<name> looks the same as for git repositories
+//
+// blink repo publish <dir> writes this layout from a recipe checkout
+/****************************************************/
+
+const httpIndexFile = "INDEX.gz"
+
+// httpClient fetches repository files, a server that stops answering
+// fails the sync instead of hanging it
+var httpClient = &http.Client{Timeout: httpRepoTimeout}
+
+// httpIndexID names the files that belong to indexData
+func httpIndexID(indexData []byte) string {
+	sum := sha256.Sum256(indexData)
+	return hex.EncodeToString(sum[:])
+}
+
+func httpSigFile(id string) string     { return "INDEX." + id + ".sig" }
+func httpRecipesFile(id string) string { return "recipes." + id + ".tar.gz" }
+
+// gunzipIndex unpacks INDEX.gz
+func gunzipIndex(gz []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(gz))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(zr)
+}
+
+// httpValidators are the caching headers of the last INDEX.gz download
+type httpValidators struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// httpCachePath keeps the validators next to the repository
+func httpCachePath(name string) string {
+	return filepath.Join(repoCachePath, name+".http.json")
+}
+
+/****************************************************/
+// fetchConditional downloads url unless it still matches v, nil data
+// means the server answered 304 Not Modified
+/****************************************************/
+func fetchConditional(url string, v httpValidators) ([]byte, httpValidators, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, v, err
+	}
+	if v.ETag != "" {
+		req.Header.Set("If-None-Match", v.ETag)
+	}
+	if v.LastModified != "" {
+		req.Header.Set("If-Modified-Since", v.LastModified)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, v, fmt.Errorf("failed to download %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, v, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, v, fmt.Errorf("failed to download %s, status: %s", url, resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, v, fmt.Errorf("failed to download %s: %v", url, err)
+	}
+
+	return data, httpValidators{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}, nil
+}
+
+// fetchOptional downloads url, false if the server has no such file
+func fetchOptional(url string) ([]byte, bool, error) {
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to download %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("failed to download %s, status: %s", url, resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	return data, err == nil, err
+}
+
+/****************************************************/
+// syncHTTPRepo brings an http repository up to date, force ignores the
+// cached validators. a repository that fails verification is left as
+// it was. the server only has its latest state, so a locked revision
+// (want) can only be checked, not picked
+/****************************************************/
+func syncHTTPRepo(repo RepoConfig, force bool, want string) (RepoIndex, bool, error) {
+	base := strings.TrimSuffix(repo.URL, "/")
+	dir := repoDir(repo)
+
+	var cached httpValidators
+	_, statErr := os.Stat(dir)
+	if data, err := os.ReadFile(httpCachePath(repo.Name)); err == nil && !force && statErr == nil {
+		json.Unmarshal(data, &cached)
+	}
+
+	gz, validators, err := fetchConditional(base+"/"+httpIndexFile, cached)
+	if err != nil {
+		return RepoIndex{}, false, err
+	}
+	if gz == nil {
+		if want != "" {
+			if have, err := repoRevision(repo); err != nil || have != want {
+				return RepoIndex{}, false, fmt.Errorf("repository %s is at %s, the lock wants %s, http repositories only serve their latest state", repo.Name, shortRevision(have), shortRevision(want))
+			}
+		}
+		eyes.Infof("Repository %s is up to date.", repo.Name)
+		return verifyRepo(repo, dir) // from signing.go
+	}
+
+	indexData, err := gunzipIndex(gz)
+	if err != nil {
+		return RepoIndex{}, false, fmt.Errorf("corrupt %s: %v", httpIndexFile, err)
+	}
+	id := httpIndexID(indexData)
+
+	if want != "" {
+		if have := "sha256:" + id; have != want {
+			return RepoIndex{}, false, fmt.Errorf("repository %s serves %s, the lock wants %s, http repositories only serve their latest state", repo.Name, shortRevision(have), shortRevision(want))
+		}
+	}
+
+	sigData, hasSig, err := fetchOptional(base + "/" + httpSigFile(id))
+	if err != nil {
+		return RepoIndex{}, false, err
+	}
+
+	recipesFile := httpRecipesFile(id)
+	eyes.Infof("Downloading %s/%s...", base, recipesFile)
+	tarball, found, err := fetchOptional(base + "/" + recipesFile)
+	if err != nil {
+		return RepoIndex{}, false, err
+	}
+	if !found {
+		return RepoIndex{}, false, fmt.Errorf("%s has no %s", base, recipesFile)
+	}
+
+	// unpack next to the current copy, only swap once it checks out
+	stage := dir + ".new"
+	if err := os.RemoveAll(stage); err != nil {
+		return RepoIndex{}, false, err
+	}
+	defer os.RemoveAll(stage)
+
+	if err := extractRecipes(tarball, stage); err != nil {
+		return RepoIndex{}, false, fmt.Errorf("failed to unpack %s: %v", recipesFile, err)
+	}
+	if err := os.WriteFile(filepath.Join(stage, repoIndexFile), indexData, 0644); err != nil {
+		return RepoIndex{}, false, err
+	}
+	if hasSig {
+		if err := os.WriteFile(filepath.Join(stage, repoSigFile), sigData, 0644); err != nil {
+			return RepoIndex{}, false, err
+		}
+	}
+
+	index, signed, err := verifyRepo(repo, stage)
+	if err != nil {
+		return RepoIndex{}, false, fmt.Errorf("refusing repository %s: %v", repo.Name, err)
+	}
+	if !signed {
+		// nothing vouches for the files, but they should at least be
+		// the ones INDEX describes
+		if err := json.Unmarshal(indexData, &index); err != nil {
+			return RepoIndex{}, false, fmt.Errorf("refusing repository %s: corrupt %s: %v", repo.Name, repoIndexFile, err)
+		}
+		if err := checkRepoFiles(index, stage); err != nil {
+			return RepoIndex{}, false, fmt.Errorf("refusing repository %s: %v", repo.Name, err)
+		}
+	}
+
+	old := dir + ".old"
+	os.RemoveAll(old)
+	if statErr == nil {
+		if err := os.Rename(dir, old); err != nil {
+			return RepoIndex{}, false, err
+		}
+	}
+	if err := os.Rename(stage, dir); err != nil {
+		os.Rename(old, dir)
+		return RepoIndex{}, false, err
+	}
+	os.RemoveAll(old)
+
+	if data, err := json.Marshal(validators); err == nil {
+		if err := os.WriteFile(httpCachePath(repo.Name), data, 0644); err != nil {
+			eyes.Warnf("Could not cache the validators of %s: %v", repo.Name, err)
+		}
+	}
+
+	eyes.Infof("Repository %s updated, %d recipe(s).", repo.Name, len(index.Packages))
+	return index, signed, nil
+}
+
+/****************************************************/
+// extractRecipes unpacks a recipes tarball into dest, only plain files
+// and directories that stay inside dest
+/****************************************************/
+func extractRecipes(tarball []byte, dest string) error {
+	zr, err := gzip.NewReader(bytes.NewReader(tarball))
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(zr)
+
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return err
+	}
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		name := path.Clean(hdr.Name)
+		if name == "." {
+			continue
+		}
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("unsafe path %s", hdr.Name)
+		}
+		target := filepath.Join(dest, filepath.FromSlash(name))
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, tr)
+			f.Close()
+			if err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("%s is not a regular file", hdr.Name)
+		}
+	}
+}
+
+/****************************************************/
+// publishRepo writes the http repository layout for the recipes in dir
+// to out, signed with keyFile if one is given. the output can be served
+// from any static web server. the files of the index being replaced are
+// kept for clients in the middle of a sync, older ones are removed
+/****************************************************/
+func publishRepo(dir, out, keyFile string) error {
+	index, indexData, err := publishableIndex(dir) // from signing.go
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(out, 0755); err != nil {
+		return err
+	}
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	if _, err := zw.Write(indexData); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	var tarball bytes.Buffer
+	zw = gzip.NewWriter(&tarball)
+	tw := tar.NewWriter(zw)
+	for _, e := range index.Packages {
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(e.Path)))
+		if err != nil {
+			return err
+		}
+		hdr := &tar.Header{
+			Name:     e.Path,
+			Mode:     0644,
+			Size:     int64(len(data)),
+			ModTime:  index.Generated,
+			Typeflag: tar.TypeReg,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	id := httpIndexID(indexData)
+	names := []string{httpRecipesFile(id)}
+	files := map[string][]byte{
+		httpIndexFile:       gz.Bytes(),
+		httpRecipesFile(id): tarball.Bytes(),
+	}
+	if keyFile != "" {
+		sig, fingerprint, err := signIndex(indexData, keyFile)
+		if err != nil {
+			return err
+		}
+		names = append(names, httpSigFile(id))
+		files[httpSigFile(id)] = sig
+		eyes.Infof("Signed with %s.", fingerprint)
+	}
+	names = append(names, httpIndexFile)
+
+	// the index being replaced, its files stay
+	keep := map[string]bool{}
+	if old, err := os.ReadFile(filepath.Join(out, httpIndexFile)); err == nil {
+		if oldData, err := gunzipIndex(old); err == nil {
+			oldID := httpIndexID(oldData)
+			keep[httpSigFile(oldID)] = true
+			keep[httpRecipesFile(oldID)] = true
+		}
+	}
+
+	// INDEX.gz last, a client that sees the new index finds the
+	// signature and tarball that go with it
+	for _, name := range names {
+		data := files[name]
+		keep[name] = true
+		tmp := filepath.Join(out, name+".tmp")
+		if err := os.WriteFile(tmp, data, 0644); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp, filepath.Join(out, name)); err != nil {
+			return err
+		}
+	}
+
+	// files of older indexes, and the unversioned ones of the old layout
+	entries, err := os.ReadDir(out)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		name := e.Name()
+		stale := name == "INDEX.sig" || name == "recipes.tar.gz" ||
+			(strings.HasPrefix(name, "INDEX.") && strings.HasSuffix(name, ".sig")) ||
+			(strings.HasPrefix(name, "recipes.") && strings.HasSuffix(name, ".tar.gz"))
+		if stale && !keep[name] {
+			if err := os.Remove(filepath.Join(out, name)); err != nil {
+				eyes.Warnf("Could not remove %s: %v", name, err)
+			}
+		}
+	}
+
+	eyes.Successf("Published %d recipe(s) to %s.", len(index.Packages), out)
+	return nil
+}
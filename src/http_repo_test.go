@@ -0,0 +1,112 @@
+/*
+  Blink, a powerful source-based package manager. Core of ApertureOS.
+	Want to use it for your own project?
+	Blink is completely FOSS (Free and Open Source),
+	edit, publish, use, contribute to Blink however you prefer.
+  Copyright (C) 2025-2026 Aperture OS
+
+  This program is free software: you can redistribute it and/or modify
+  it under the terms of the Apache 2.0 License as published by
+  the Apache Software Foundation, either version 2.0 of the License, or
+  any later version.
+
+  This program is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <https://www.apache.org/licenses/LICENSE-2.0>.
+*/
+
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// tarball gzips a tar of the given headers, regular files get their
+// name as content
+func tarball(t *testing.T, headers ...tar.Header) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(zw)
+	for _, hdr := range headers {
+		var body []byte
+		if hdr.Typeflag == tar.TypeReg {
+			body = []byte(hdr.Name)
+			hdr.Size = int64(len(body))
+		}
+		if hdr.Mode == 0 {
+			hdr.Mode = 0644
+		}
+		if err := tw.WriteHeader(&hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestExtractRecipes(t *testing.T) {
+	dest := filepath.Join(t.TempDir(), "recipes")
+	data := tarball(t,
+		tar.Header{Name: "./", Typeflag: tar.TypeDir},
+		tar.Header{Name: "foo.json", Typeflag: tar.TypeReg},
+		tar.Header{Name: "extra/", Typeflag: tar.TypeDir},
+		tar.Header{Name: "extra/bar.json", Typeflag: tar.TypeReg},
+		tar.Header{Name: "a/../baz.json", Typeflag: tar.TypeReg},
+	)
+
+	if err := extractRecipes(data, dest); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []string{"foo.json", "extra/bar.json", "baz.json"} {
+		if _, err := os.Stat(filepath.Join(dest, f)); err != nil {
+			t.Errorf("%s was not extracted: %v", f, err)
+		}
+	}
+}
+
+func TestExtractRecipesUnsafe(t *testing.T) {
+	tests := []struct {
+		name string
+		hdr  tar.Header
+	}{
+		{"parent", tar.Header{Name: "../evil.json", Typeflag: tar.TypeReg}},
+		{"nested parent", tar.Header{Name: "a/../../evil.json", Typeflag: tar.TypeReg}},
+		{"parent dir", tar.Header{Name: "..", Typeflag: tar.TypeDir}},
+		{"absolute", tar.Header{Name: "/tmp/evil.json", Typeflag: tar.TypeReg}},
+		{"symlink", tar.Header{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "/etc"}},
+		{"hardlink", tar.Header{Name: "link", Typeflag: tar.TypeLink, Linkname: "/etc/passwd"}},
+	}
+
+	for _, tt := range tests {
+		root := t.TempDir()
+		dest := filepath.Join(root, "recipes")
+
+		if err := extractRecipes(tarball(t, tt.hdr), dest); err == nil {
+			t.Errorf("%s: %s was extracted", tt.name, tt.hdr.Name)
+		}
+
+		for _, f := range []string{"evil.json", "link", "recipes/link"} {
+			if _, err := os.Lstat(filepath.Join(root, f)); err == nil {
+				t.Errorf("%s: %s was written", tt.name, f)
+			}
+		}
+	}
+}
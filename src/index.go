@@ -0,0 +1,197 @@
+/*
+  Blink, a powerful source-based package manager. Core of ApertureOS.
+	Want to use it for your own project?
+	Blink is completely FOSS (Free and Open Source),
+	edit, publish, use, contribute to Blink however you prefer.
+  Copyright (C) 2025-2026 Aperture OS
+
+  This program is free software: you can redistribute it and/or modify
+  it under the terms of the Apache 2.0 License as published by
+  the Apache Software Foundation, either version 2.0 of the License, or
+  any later version.
+
+  This program is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <https://www.apache.org/licenses/LICENSE-2.0>.
+*/
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Aperture-OS/eyes"
+)
+
+/****************************************************/
+// Package index: every sync walks the clone of a repository and writes
+// a summary of each recipe to repoCachePath/<name>.index.json, so
+// search (and anything else that wants to look at a whole repository)
+// reads one file instead of decoding hundreds of recipes
+/****************************************************/
+
+// RepoIndex is a repository's <name>.index.json
+type RepoIndex struct {
+	Generated time.Time    `json:"generated"`
+	Packages  []IndexEntry `json:"packages"`
+}
+
+// IndexEntry is the summary of one recipe
+type IndexEntry struct {
+	Name         string            `json:"name"` // file name without .json, what install takes
+	Epoch        int               `json:"epoch,omitempty"`
+	Version      string            `json:"version"`
+	Release      int64             `json:"release"`
+	Description  string            `json:"description,omitempty"`
+	License      string            `json:"license,omitempty"`
+	Dependencies map[string]string `json:"dependencies,omitempty"`
+	Path         string            `json:"path"`   // recipe file, relative to the repository root
+	Sha256       string            `json:"sha256"` // of the recipe file
+}
+
+// evr returns the indexed recipe's epoch:version-release
+func (e IndexEntry) evr() pkgVersion {
+	return pkgVersion{Epoch: e.Epoch, Version: e.Version, Release: e.Release}
+}
+
+// indexPath is where the index of a repository lives, next to its clone
+func indexPath(name string) string {
+	return filepath.Join(repoCachePath, name+".index.json")
+}
+
+/****************************************************/
+// buildRepoIndex indexes every *.json recipe at the top of dir, the
+// <repo>/<name>.json layout resolveRecipe reads, subdirectories (.git
+// included) are never recipes. files that don't decode as a recipe are returned as problems, they
+// don't stop the rest of the repository from being indexed
+/****************************************************/
+func buildRepoIndex(dir string) (RepoIndex, []string, error) {
+	index := RepoIndex{Generated: time.Now().UTC()}
+	var problems []string
+
+	err := filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() && p != dir {
+			return filepath.SkipDir
+		}
+		if fi.IsDir() || !strings.HasSuffix(fi.Name(), ".json") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+
+		var pkg PackageInfo
+		if err := json.Unmarshal(data, &pkg); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", rel, err))
+			return nil
+		}
+
+		sum := sha256.Sum256(data)
+		index.Packages = append(index.Packages, IndexEntry{
+			Name:         strings.TrimSuffix(fi.Name(), ".json"),
+			Epoch:        pkg.Epoch,
+			Version:      pkg.Version,
+			Release:      int64(pkg.Release),
+			Description:  pkg.Description,
+			License:      pkg.License,
+			Dependencies: pkg.Dependencies,
+			Path:         filepath.ToSlash(rel),
+			Sha256:       hex.EncodeToString(sum[:]),
+		})
+		return nil
+	})
+	if err != nil {
+		return RepoIndex{}, nil, err
+	}
+
+	sort.Slice(index.Packages, func(i, j int) bool {
+		if index.Packages[i].Name != index.Packages[j].Name {
+			return index.Packages[i].Name < index.Packages[j].Name
+		}
+		return index.Packages[i].Path < index.Packages[j].Path
+	})
+
+	return index, problems, nil
+}
+
+/****************************************************/
+// writeRepoIndex rebuilds the index of a synced repository
+/****************************************************/
+func writeRepoIndex(repo RepoConfig) error {
+	index, problems, err := buildRepoIndex(repoDir(repo))
+	if err != nil {
+		return err
+	}
+	for _, p := range problems {
+		eyes.Warnf("%s: skipping %s", repo.Name, p)
+	}
+
+	return saveRepoIndex(repo.Name, index)
+}
+
+// saveRepoIndex writes the index of a repository, signed repositories
+// store the index they were verified against
+func saveRepoIndex(name string, index RepoIndex) error {
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := indexPath(name) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, indexPath(name))
+}
+
+/****************************************************/
+// loadRepoIndex reads the index of a repository. clones synced by an
+// older Blink have none yet and local repositories never have one,
+// those get indexed in memory (search runs without root, so it can't
+// write one)
+/****************************************************/
+func loadRepoIndex(repo RepoConfig) (RepoIndex, error) {
+	name := repo.Name
+	data, err := os.ReadFile(indexPath(name))
+	if repo.Type == repoTypeLocal || os.IsNotExist(err) {
+		dir := repoDir(repo)
+		if _, err := os.Stat(dir); err != nil {
+			if repo.Type == repoTypeLocal {
+				return RepoIndex{}, err
+			}
+			return RepoIndex{}, fmt.Errorf("repository %s is not synced, run 'blink sync' first", name)
+		}
+		index, _, err := buildRepoIndex(dir)
+		return index, err
+	}
+	if err != nil {
+		return RepoIndex{}, err
+	}
+
+	var index RepoIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		return RepoIndex{}, fmt.Errorf("corrupt index %s: %v", indexPath(name), err)
+	}
+	return index, nil
+}
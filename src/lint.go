@@ -0,0 +1,520 @@
+/*
+  Blink, a powerful source-based package manager. Core of ApertureOS.
+	Want to use it for your own project?
+	Blink is completely FOSS (Free and Open Source),
+	edit, publish, use, contribute to Blink however you prefer.
+  Copyright (C) 2025-2026 Aperture OS
+
+  This program is free software: you can redistribute it and/or modify
+  it under the terms of the Apache 2.0 License as published by
+  the Apache Software Foundation, either version 2.0 of the License, or
+  any later version.
+
+  This program is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <https://www.apache.org/licenses/LICENSE-2.0>.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+/****************************************************/
+// lint validates package recipes way more strictly than fetchpkg does
+// fetchpkg just json.Decode's whatever it gets, which is fine for installing
+// but terrible for a repository CI, typos like "kind": "tocompile" or
+// "sah256" only blow up on the user's machine. lint catches them early and
+// prints every problem as file:field: message so CI logs are easy to read
+/****************************************************/
+
+// lintIssue is a single problem found in a recipe
+type lintIssue struct {
+	File    string
+	Field   string
+	Message string
+	Warning bool // warnings are printed but don't fail the lint
+}
+
+func (i lintIssue) String() string {
+	level := "error"
+	if i.Warning {
+		level = "warning"
+	}
+	return fmt.Sprintf("%s:%s: %s: %s", i.File, i.Field, level, i.Message)
+}
+
+// recipeLinter collects issues for one file
+type recipeLinter struct {
+	file   string
+	known  map[string]bool // package names available in the configured repos (nil = unknown)
+	issues []lintIssue
+}
+
+func (l *recipeLinter) errorf(field, format string, args ...any) {
+	l.issues = append(l.issues, lintIssue{File: l.file, Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (l *recipeLinter) warnf(field, format string, args ...any) {
+	l.issues = append(l.issues, lintIssue{File: l.file, Field: field, Message: fmt.Sprintf(format, args...), Warning: true})
+}
+
+var (
+	pkgNameRe  = regexp.MustCompile(`^[a-z0-9][a-z0-9+._-]*$`)
+	sha256Re   = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+	envKeyRe   = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	versionRe  = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._+~^]*$`) // no "-", it separates the release
+	buildKinds = []string{"toCompile", "preCompiled"}
+
+	// same list decompressSource understands
+	sourceTypes = []string{"tar.gz", "tgz", "tar.xz", "tar.bz2", "zip"}
+)
+
+/****************************************************/
+// lintRecipes lints a single recipe file or every *.json in a directory
+// (recursively, .git is skipped). it returns all issues found, sorted by file
+/****************************************************/
+func lintRecipes(target string) ([]lintIssue, error) {
+	info, err := os.Stat(target)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	if info.IsDir() {
+		err := filepath.Walk(target, func(p string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if fi.IsDir() && fi.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			if !fi.IsDir() && strings.HasSuffix(fi.Name(), ".json") {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		files = []string{target}
+	}
+
+	sort.Strings(files)
+
+	var issues []lintIssue
+
+	// an issue rather than a log line, so the summary counts it and a
+	// clean run can't be mistaken for one that checked dependency names
+	known := knownRepoPackages()
+	if known == nil {
+		issues = append(issues, lintIssue{
+			File:    target,
+			Field:   "-",
+			Message: "dependency names not checked, no synced repositories (run 'blink sync' first)",
+			Warning: true,
+		})
+	} else {
+		// recipes being linted can depend on each other
+		for _, f := range files {
+			known[strings.TrimSuffix(filepath.Base(f), ".json")] = true
+		}
+	}
+
+	for _, f := range files {
+		l := &recipeLinter{file: f, known: known}
+		l.lintFile()
+		issues = append(issues, l.issues...)
+	}
+
+	return issues, nil
+}
+
+/****************************************************/
+// knownRepoPackages lists every recipe name in the local repo clones
+// returns nil when there is nothing to check against
+/****************************************************/
+func knownRepoPackages() map[string]bool {
+	repos, err := LoadRepos(configPath)
+	if err != nil || len(repos) == 0 {
+		return nil
+	}
+
+	known := make(map[string]bool)
+	found := false
+
+	for _, repo := range repos {
+		root := repoDir(repo)
+		if _, err := os.Stat(root); err != nil {
+			continue
+		}
+		found = true
+
+		filepath.Walk(root, func(p string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return nil
+			}
+			if fi.IsDir() && fi.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			if !fi.IsDir() && strings.HasSuffix(fi.Name(), ".json") {
+				known[strings.TrimSuffix(fi.Name(), ".json")] = true
+			}
+			return nil
+		})
+	}
+
+	if !found {
+		return nil
+	}
+	return known
+}
+
+/****************************************************/
+// lintFile runs every check on l.file
+/****************************************************/
+func (l *recipeLinter) lintFile() {
+	data, err := os.ReadFile(l.file)
+	if err != nil {
+		l.errorf("-", "cannot read file: %v", err)
+		return
+	}
+
+	// pass 1: unknown keys and wrong types, walking the raw JSON
+	var raw any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		l.errorf("-", "invalid JSON: %v", err)
+		return
+	}
+	l.checkKeys(raw, reflect.TypeOf(PackageInfo{}), "")
+
+	// pass 2: decode for real, type errors were already reported field by
+	// field above so Unmarshal just fills in whatever it can
+	var pkg PackageInfo
+	json.Unmarshal(data, &pkg)
+
+	l.checkMetadata(pkg)
+	l.checkSource(pkg)
+	l.checkDeps(pkg)
+	l.checkBuild(pkg)
+}
+
+/****************************************************/
+// checkKeys compares the raw JSON against the json tags of t
+// so the schema always matches PackageInfo, no second list to maintain
+/****************************************************/
+func (l *recipeLinter) checkKeys(v any, t reflect.Type, field string) {
+	if v == nil {
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		obj, ok := v.(map[string]any)
+		if !ok {
+			l.errorf(fieldOr(field), "expected an object, got %s", jsonKind(v))
+			return
+		}
+
+		fields := make(map[string]reflect.Type)
+		for i := 0; i < t.NumField(); i++ {
+			tag := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+			if tag != "" && tag != "-" {
+				fields[tag] = t.Field(i).Type
+			}
+		}
+
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			ft, ok := fields[k]
+			if !ok {
+				l.errorf(joinField(field, k), "unknown key %q", k)
+				continue
+			}
+			l.checkKeys(obj[k], ft, joinField(field, k))
+		}
+
+	case reflect.Slice:
+		arr, ok := v.([]any)
+		if !ok {
+			l.errorf(field, "expected an array, got %s", jsonKind(v))
+			return
+		}
+		for i, item := range arr {
+			l.checkKeys(item, t.Elem(), fmt.Sprintf("%s[%d]", field, i))
+		}
+
+	case reflect.Map:
+		obj, ok := v.(map[string]any)
+		if !ok {
+			l.errorf(field, "expected an object, got %s", jsonKind(v))
+			return
+		}
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			l.checkKeys(obj[k], t.Elem(), joinField(field, k))
+		}
+
+	case reflect.String:
+		if _, ok := v.(string); !ok {
+			l.errorf(field, "expected a string, got %s", jsonKind(v))
+		}
+
+	case reflect.Int, reflect.Int64:
+		n, ok := v.(float64)
+		if !ok {
+			l.errorf(field, "expected an integer, got %s", jsonKind(v))
+		} else if n != float64(int64(n)) {
+			l.errorf(field, "expected an integer, got %v", n)
+		}
+
+	case reflect.Bool:
+		if _, ok := v.(bool); !ok {
+			l.errorf(field, "expected a boolean, got %s", jsonKind(v))
+		}
+	}
+}
+
+func (l *recipeLinter) checkMetadata(pkg PackageInfo) {
+	base := strings.TrimSuffix(filepath.Base(l.file), ".json")
+
+	switch {
+	case pkg.Name == "":
+		l.errorf("name", "required field is missing")
+	case !pkgNameRe.MatchString(pkg.Name):
+		l.errorf("name", "%q must be lowercase and only contain a-z, 0-9, +, ., _ and -", pkg.Name)
+	case pkg.Name != base:
+		l.errorf("name", "%q must match the file name %q", pkg.Name, base+".json")
+	}
+
+	switch {
+	case pkg.Version == "":
+		l.errorf("version", "required field is missing")
+	case !versionRe.MatchString(pkg.Version):
+		l.errorf("version", "%q contains invalid characters (no spaces, ':' or '-', use the epoch and release fields instead)", pkg.Version)
+	}
+
+	if pkg.Epoch < 0 {
+		l.errorf("epoch", "must not be negative")
+	}
+
+	if pkg.Release <= 0 {
+		l.errorf("release", "required field is missing or not a positive unix timestamp")
+	}
+
+	if strings.TrimSpace(pkg.Description) == "" {
+		l.errorf("description", "required field is missing")
+	}
+
+	if strings.TrimSpace(pkg.Author) == "" {
+		l.warnf("author", "field is empty")
+	}
+
+	if pkg.License == "" {
+		l.errorf("license", "required field is missing")
+	} else if err := checkSPDXExpression(pkg.License); err != nil {
+		l.errorf("license", "%v", err)
+	}
+}
+
+func (l *recipeLinter) checkSource(pkg PackageInfo) {
+	if pkg.Source.URL == "" {
+		l.errorf("source.url", "required field is missing")
+	} else if u, err := url.Parse(pkg.Source.URL); err != nil {
+		l.errorf("source.url", "invalid URL: %v", err)
+	} else if u.Scheme != "http" && u.Scheme != "https" {
+		l.errorf("source.url", "scheme must be http or https, got %q", u.Scheme)
+	} else if u.Host == "" {
+		l.errorf("source.url", "URL has no host")
+	} else if !hasSupportedArchiveSuffix(u.Path) {
+		l.errorf("source.url", "%q is not a supported archive (%s)", filepath.Base(u.Path), strings.Join(sourceTypes, ", "))
+	}
+
+	if pkg.Source.Sha256 == "" {
+		l.errorf("source.sha256", "required field is missing")
+	} else if !sha256Re.MatchString(pkg.Source.Sha256) {
+		l.errorf("source.sha256", "must be 64 hexadecimal characters")
+	}
+
+	srcType := strings.TrimPrefix(pkg.Source.Type, ".")
+	if srcType == "" {
+		l.errorf("source.type", "required field is missing")
+	} else if !containsString(sourceTypes, srcType) {
+		l.errorf("source.type", "unknown archive type %q, expected one of %s", pkg.Source.Type, strings.Join(sourceTypes, ", "))
+	} else if pkg.Source.URL != "" && !strings.HasSuffix(pkg.Source.URL, "."+srcType) {
+		l.warnf("source.type", "%q does not match the URL extension", pkg.Source.Type)
+	}
+}
+
+func (l *recipeLinter) checkDeps(pkg PackageInfo) {
+	names := make([]string, 0, len(pkg.Dependencies))
+	for dep := range pkg.Dependencies {
+		names = append(names, dep)
+	}
+	sort.Strings(names)
+
+	for _, dep := range names {
+		field := joinField("dependencies", dep)
+		if dep == pkg.Name {
+			l.errorf(field, "package depends on itself")
+		}
+		if _, err := parseConstraints(pkg.Dependencies[dep]); err != nil {
+			l.errorf(field, "%v", err)
+		}
+		l.checkPackageExists(field, dep)
+	}
+
+	ids := make(map[int]bool)
+	for i, group := range pkg.OptDeps {
+		field := fmt.Sprintf("opt_dependencies[%d]", i)
+
+		if ids[group.ID] {
+			l.errorf(field+".id", "duplicate group id %d", group.ID)
+		}
+		ids[group.ID] = true
+
+		if strings.TrimSpace(group.Description) == "" {
+			l.warnf(field+".description", "field is empty")
+		}
+
+		if len(group.Options) == 0 {
+			l.errorf(field+".options", "group has no options")
+		}
+		for j, opt := range group.Options {
+			l.checkPackageExists(fmt.Sprintf("%s.options[%d]", field, j), opt)
+		}
+
+		if group.Default != "" && !containsString(group.Options, group.Default) {
+			l.errorf(field+".default", "%q is not one of the group's options", group.Default)
+		}
+	}
+}
+
+func (l *recipeLinter) checkBuild(pkg PackageInfo) {
+	switch {
+	case pkg.Build.Kind == "":
+		l.errorf("build.kind", "required field is missing")
+	case !containsString(buildKinds, pkg.Build.Kind):
+		l.errorf("build.kind", "unknown kind %q, expected one of %s", pkg.Build.Kind, strings.Join(buildKinds, ", "))
+	}
+
+	if pkg.Build.Kind == "toCompile" && len(pkg.Build.Install) == 0 {
+		l.errorf("build.install", "toCompile packages need at least one install command")
+	}
+
+	envKeys := make([]string, 0, len(pkg.Build.Env))
+	for k := range pkg.Build.Env {
+		envKeys = append(envKeys, k)
+	}
+	sort.Strings(envKeys)
+
+	for _, k := range envKeys {
+		if !envKeyRe.MatchString(k) {
+			l.errorf(joinField("build.env", k), "invalid environment variable name")
+		}
+	}
+
+	for i, c := range pkg.Build.Config {
+		if !strings.HasPrefix(c, "/") {
+			l.errorf(fmt.Sprintf("build.config[%d]", i), "%q must be an absolute path or glob", c)
+		}
+	}
+
+	// a slice, not a map, so they're always reported in this order
+	for _, list := range []struct {
+		name string
+		cmds []string
+	}{
+		{"build.prepare", pkg.Build.Prepare},
+		{"build.install", pkg.Build.Install},
+		{"build.uninstall", pkg.Build.Uninstall},
+	} {
+		for i, c := range list.cmds {
+			if strings.TrimSpace(c) == "" {
+				l.errorf(fmt.Sprintf("%s[%d]", list.name, i), "empty command")
+			}
+		}
+	}
+}
+
+// checkPackageExists reports a package name that no configured repo provides
+func (l *recipeLinter) checkPackageExists(field, name string) {
+	if l.known != nil && !l.known[name] {
+		l.errorf(field, "package %q not found in any configured repository", name)
+	}
+}
+
+/****************************************************/
+// small helpers, nothing fancy
+/****************************************************/
+
+func hasSupportedArchiveSuffix(p string) bool {
+	for _, t := range sourceTypes {
+		if strings.HasSuffix(p, "."+t) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func joinField(parent, key string) string {
+	if parent == "" {
+		return key
+	}
+	return parent + "." + key
+}
+
+func fieldOr(field string) string {
+	if field == "" {
+		return "-"
+	}
+	return field
+}
+
+func jsonKind(v any) string {
+	switch v.(type) {
+	case map[string]any:
+		return "an object"
+	case []any:
+		return "an array"
+	case string:
+		return "a string"
+	case float64:
+		return "a number"
+	case bool:
+		return "a boolean"
+	}
+	return "null"
+}
@@ -25,6 +25,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 
 	"charm.land/lipgloss/v2"
 	"github.com/charmbracelet/fang" // For fancy terminal output
@@ -116,8 +118,25 @@ func main() {
 	})
 
 	// Flags for CLI commands
-	var force bool  // Force re-download or reinstall
-	var path string // Custom cache path
+	var force bool      // Force re-download or reinstall
+	var path string     // Custom cache path
+	var root string     // Alternate installation root
+	var jsonOutput bool // JSON output for query commands
+	var configOK bool   // verify: skip files marked as config
+	var dryRun bool     // only show what would be done
+	var cascade bool    // uninstall: also remove packages depending on the target
+	var nodeps bool     // uninstall: ignore reverse dependencies
+	var branch string   // repo add: branch to track
+	var priority int    // repo add: resolution priority
+	var useRegex bool   // search: query is a regular expression
+	var useFuzzy bool   // search: also match letters in order
+	var signedRepo bool // repo add: require a signed index
+	var keyFile string  // repo sign/publish: private key to sign with
+	var repoType string // repo add: git or http
+	var output string   // repo publish: output directory
+	var lockRepos bool  // sync: write the repository lock file
+	var frozen bool     // sync: check out exactly what the lock file says
+	var lockFile string // sync: lock file to write or read
 
 	/****************************************************/
 	//  Root command
@@ -126,6 +145,17 @@ func main() {
 		Use:   "blink",
 		Short: fmt.Sprintf("Blink - lightweight, source-based package manager for %s", distroName),
 		Long:  fmt.Sprintf("Blink - lightweight, fast, source-based package manager for %s and Linux systems.", distroName),
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := setRoot(root); err != nil {
+				return err
+			}
+			// recipe commands can find the target filesystem through this
+			os.Setenv("BLINK_ROOT", installRoot)
+
+			// a crashed transaction gets rolled back before anything looks at the system
+			recoverAtStartup()
+			return nil
+		},
 	}
 
 	/****************************************************/
@@ -141,7 +171,7 @@ func main() {
 			requireRoot() // ensure running as root
 
 			if path == "" {
-				path = filepath.Join(defaultCachePath, "recipes")
+				path = defaultCachePath
 			}
 			for _, pkgName := range args {
 				if err := getpkg(pkgName, path); err != nil {
@@ -154,19 +184,19 @@ func main() {
 	}
 
 	/****************************************************/
-	//  blink search <pkg>
+	//  blink info <pkg>
 	/****************************************************/
 	infoCmd := &cobra.Command{
-		Use:     "search <pkg>",
+		Use:     "info <pkg>",
 		Short:   "Fetch & display package information",
 		Args:    cobra.ExactArgs(1),
-		Aliases: []string{"information", "pkginfo", "details", "fetch", "info", "f", "searchfor"},
+		Aliases: []string{"information", "pkginfo", "details", "fetch", "f"},
 		Run: func(cmd *cobra.Command, args []string) {
 
 			requireRoot() // ensure running as root
 
 			if path == "" {
-				path = filepath.Join(defaultCachePath, "recipes")
+				path = defaultCachePath
 			}
 
 			for _, pkgName := range args {
@@ -179,12 +209,39 @@ func main() {
 		},
 	}
 
+	/****************************************************/
+	//  blink search <query>
+	//  searches the package index of every repository, no root needed
+	/****************************************************/
+	searchCmd := &cobra.Command{
+		Use:     "search <query>",
+		Short:   "Search package names and descriptions in all repositories",
+		Args:    cobra.ExactArgs(1),
+		Aliases: []string{"searchfor", "find"},
+		Run: func(cmd *cobra.Command, args []string) {
+
+			mode := searchSubstring
+			switch {
+			case useRegex && useFuzzy:
+				eyes.Fatalf("--regex and --fuzzy can't be used together")
+			case useRegex:
+				mode = searchRegex
+			case useFuzzy:
+				mode = searchFuzzy
+			}
+
+			if err := showSearch(args[0], mode, jsonOutput); err != nil {
+				eyes.Fatalf("Search failed: %v", err)
+			}
+		},
+	}
+
 	/****************************************************/
 	//  blink install <pkg>
 	/****************************************************/
 	installCmd := &cobra.Command{
-		Use:     "install <pkg>",
-		Short:   "Download and install a package",
+		Use:     "install <pkg>[@version]",
+		Short:   "Download and install a package, or a specific (older) version of it",
 		Args:    cobra.ExactArgs(1),
 		Aliases: []string{"i", "add", "inst"},
 		Run: func(cmd *cobra.Command, args []string) {
@@ -192,16 +249,30 @@ func main() {
 			requireRoot() // ensure running as root
 
 			if path == "" {
-				path = filepath.Join(defaultCachePath, "recipes")
+				path = defaultCachePath
 			}
 
-			for _, pkgName := range args {
-				eyes.Infof("Processing package: %s", pkgName)
-
-				if err := install(pkgName, force, path); err != nil {
-					eyes.Errorf("Failed to install %s: %v", pkgName, err)
-					return
+			err := withTransaction("install", args, func() error {
+				for _, pkgName := range args {
+					eyes.Infof("Processing package: %s", pkgName)
+
+					// pkg@version installs that exact version, even an older one
+					if strings.Contains(pkgName, "@") {
+						if err := installVersion(pkgName, force, path); err != nil {
+							return fmt.Errorf("failed to install %s: %v", pkgName, err)
+						}
+						continue
+					}
+
+					if err := install(pkgName, force, path, reasonExplicit); err != nil {
+						return fmt.Errorf("failed to install %s: %v", pkgName, err)
+					}
 				}
+				return nil
+			})
+			if err != nil {
+				eyes.Errorf("%v", err)
+				return
 			}
 
 		},
@@ -212,7 +283,7 @@ func main() {
 	/****************************************************/
 	uninstallCmd := &cobra.Command{
 		Use:     "uninstall <pkg>",
-		Short:   "Download and install a package",
+		Short:   "Uninstall a package (removes its recorded files)",
 		Args:    cobra.ExactArgs(1),
 		Aliases: []string{"remove", "u", "uninst"},
 		Run: func(cmd *cobra.Command, args []string) {
@@ -220,16 +291,27 @@ func main() {
 			requireRoot() // ensure running as root
 
 			if path == "" {
-				path = filepath.Join(defaultCachePath, "recipes")
+				path = defaultCachePath
 			}
 
-			for _, pkgName := range args {
-				eyes.Infof("Processing package: %s", pkgName)
+			err := withTransaction("uninstall", args, func() error {
+				order, err := planUninstall(args, path, cascade, nodeps)
+				if err != nil {
+					return err
+				}
 
-				if err := uninstall(pkgName, force, path); err != nil {
-					eyes.Errorf("Failed to uninstall %s: %v", pkgName, err)
-					return
+				for _, pkgName := range order {
+					eyes.Infof("Processing package: %s", pkgName)
+
+					if err := uninstall(pkgName, force, path); err != nil {
+						return fmt.Errorf("failed to uninstall %s: %v", pkgName, err)
+					}
 				}
+				return nil
+			})
+			if err != nil {
+				eyes.Errorf("%v", err)
+				return
 			}
 
 		},
@@ -242,15 +324,35 @@ func main() {
 		Use:     "sync",
 		Short:   "Syncs the package repository to the latest version.",
 		Args:    cobra.NoArgs,
-		Aliases: []string{"s", "--sync", "repo", "reposync"},
+		Aliases: []string{"s", "--sync", "reposync"},
 		Run: func(cmd *cobra.Command, args []string) {
 
 			requireRoot() // ensure running as root
 
-			if err := ensureRepo(force); err != nil {
+			if lockRepos && frozen {
+				eyes.Fatalf("--lock and --frozen can't be used together")
+			}
+			if lockFile == "" {
+				lockFile = repoLockPath
+			}
+
+			if frozen {
+				if err := syncFrozen(lockFile); err != nil {
+					eyes.Fatalf("Failed to sync repositories: %v", err)
+				}
+				return
+			}
+
+			if err := syncAll(force, true); err != nil {
 				eyes.Fatalf("Failed to sync repositories: %v", err)
 			}
 
+			if lockRepos {
+				if err := writeRepoLock(lockFile); err != nil {
+					eyes.Fatalf("Failed to write the lock file: %v", err)
+				}
+			}
+
 		},
 	}
 
@@ -265,15 +367,454 @@ func main() {
 			requireRoot()
 
 			if path == "" {
-				path = filepath.Join(defaultCachePath, "recipes")
+				path = defaultCachePath
 			}
 
-			if err := updateAll(path); err != nil {
+			err := withTransaction("update", nil, func() error {
+				return updateAll(path)
+			})
+			if err != nil {
 				eyes.Fatalf("Update failed: %v", err)
 			}
 		},
 	}
 
+	/****************************************************/
+	// blink owns <path...>
+	// tells which package owns a file
+	/****************************************************/
+	ownsCmd := &cobra.Command{
+		Use:     "owns <path|glob>...",
+		Short:   "Show which package owns a file",
+		Args:    cobra.MinimumNArgs(1),
+		Aliases: []string{"owner", "who-owns", "o"},
+		Run: func(cmd *cobra.Command, args []string) {
+
+			results, err := queryOwns(args)
+			if err != nil {
+				eyes.Fatalf("Failed to query file owners: %v", err)
+			}
+
+			if jsonOutput {
+				if err := printJSON(results); err != nil {
+					eyes.Fatalf("Failed to encode JSON: %v", err)
+				}
+				return
+			}
+
+			for _, r := range results {
+				if !r.Owned {
+					fmt.Printf("%s is not owned by any package\n", r.Path)
+					continue
+				}
+				fmt.Printf("%s is owned by %s\n", r.Path, strings.Join(r.Owners, ", "))
+			}
+		},
+	}
+
+	/****************************************************/
+	// blink files <pkg...>
+	// lists the files a package owns
+	/****************************************************/
+	filesCmd := &cobra.Command{
+		Use:     "files <pkg|glob>...",
+		Short:   "List the files owned by an installed package",
+		Args:    cobra.MinimumNArgs(1),
+		Aliases: []string{"list-files", "ls"},
+		Run: func(cmd *cobra.Command, args []string) {
+
+			results, err := queryFiles(args)
+			if err != nil {
+				eyes.Fatalf("Failed to list files: %v", err)
+			}
+
+			if jsonOutput {
+				if err := printJSON(results); err != nil {
+					eyes.Fatalf("Failed to encode JSON: %v", err)
+				}
+				return
+			}
+
+			for _, r := range results {
+				if !r.Tracked {
+					eyes.Warnf("%s was installed before file tracking, no files recorded.", r.Package)
+					continue
+				}
+				for _, f := range r.Files {
+					fmt.Printf("%s %s\n", r.Package, f.Path)
+				}
+			}
+		},
+	}
+
+	/****************************************************/
+	// blink verify [pkg...]
+	// checks installed files against the file database
+	/****************************************************/
+	verifyCmd := &cobra.Command{
+		Use:     "verify [pkg...]",
+		Short:   "Check installed packages for modified, missing or permission-changed files",
+		Aliases: []string{"check-files", "integrity"},
+		Run: func(cmd *cobra.Command, args []string) {
+
+			issues, err := verifyPackages(args, configOK)
+			if err != nil {
+				eyes.Fatalf("Verification failed: %v", err)
+			}
+
+			for _, issue := range issues {
+				fmt.Println(issue)
+			}
+
+			if len(issues) > 0 {
+				eyes.Fatalf("%d problem(s) found.", len(issues))
+			}
+			eyes.Success("All files match the package database.")
+		},
+	}
+
+	/****************************************************/
+	// blink autoremove
+	// removes dependencies nothing needs anymore
+	/****************************************************/
+	autoremoveCmd := &cobra.Command{
+		Use:     "autoremove",
+		Short:   "Uninstall orphaned dependencies",
+		Args:    cobra.NoArgs,
+		Aliases: []string{"orphans", "prune"},
+		Run: func(cmd *cobra.Command, args []string) {
+
+			if !dryRun {
+				requireRoot() // listing is fine without root
+			}
+
+			if path == "" {
+				path = defaultCachePath
+			}
+
+			if err := autoremove(path, dryRun); err != nil {
+				eyes.Fatalf("Autoremove failed: %v", err)
+			}
+		},
+	}
+
+	/****************************************************/
+	// blink hold <pkg>[=version]... / blink unhold <pkg>...
+	// freezes packages so update leaves them alone
+	/****************************************************/
+	holdCmd := &cobra.Command{
+		Use:     "hold [pkg[=version]...]",
+		Short:   "Hold packages at their installed (or a given) version, lists holds without arguments",
+		Aliases: []string{"pin"},
+		Run: func(cmd *cobra.Command, args []string) {
+
+			if len(args) == 0 {
+				db, err := packageDB()
+				if err != nil {
+					eyes.Fatalf("Failed to load manifest: %v", err)
+				}
+				if len(db.Holds) == 0 {
+					eyes.Infof("No packages are held.")
+					return
+				}
+				for _, h := range db.Holds {
+					fmt.Printf("%s (since %s)\n", h, h.Since.Format("2006-01-02 15:04"))
+				}
+				return
+			}
+
+			requireRoot() // ensure running as root
+
+			for _, spec := range args {
+				if err := holdPackage(spec); err != nil {
+					eyes.Fatalf("Failed to hold %s: %v", spec, err)
+				}
+			}
+		},
+	}
+
+	unholdCmd := &cobra.Command{
+		Use:     "unhold <pkg>...",
+		Short:   "Release held packages",
+		Args:    cobra.MinimumNArgs(1),
+		Aliases: []string{"unpin"},
+		Run: func(cmd *cobra.Command, args []string) {
+
+			requireRoot() // ensure running as root
+
+			for _, name := range args {
+				if err := unholdPackage(name); err != nil {
+					eyes.Fatalf("Failed to unhold %s: %v", name, err)
+				}
+			}
+		},
+	}
+
+	/****************************************************/
+	// blink generations / blink rollback [n]
+	// snapshots of the installed set, and going back to one
+	/****************************************************/
+	generationsCmd := &cobra.Command{
+		Use:     "generations",
+		Short:   "List recorded system generations",
+		Args:    cobra.NoArgs,
+		Aliases: []string{"gens", "history"},
+		Run: func(cmd *cobra.Command, args []string) {
+
+			if err := showGenerations(jsonOutput); err != nil {
+				eyes.Fatalf("Failed to list generations: %v", err)
+			}
+		},
+	}
+
+	rollbackCmd := &cobra.Command{
+		Use:   "rollback [n]",
+		Short: "Return the installed packages to generation n (default: the previous one)",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+
+			requireRoot() // ensure running as root
+
+			if path == "" {
+				path = defaultCachePath
+			}
+
+			n := 0
+			if len(args) == 1 {
+				var err error
+				n, err = strconv.Atoi(args[0])
+				if err != nil || n < 1 {
+					eyes.Fatalf("Invalid generation %q", args[0])
+				}
+			}
+
+			if err := rollbackTo(n, path); err != nil {
+				eyes.Fatalf("Rollback failed: %v", err)
+			}
+		},
+	}
+
+	/****************************************************/
+	// blink repo add/remove/list/enable/disable
+	// manages the repositories in config.toml and their clones
+	/****************************************************/
+	repoCmd := &cobra.Command{
+		Use:     "repo",
+		Short:   "Manage package repositories",
+		Aliases: []string{"repos", "repository"},
+	}
+
+	repoAddCmd := &cobra.Command{
+		Use:   "add <name> <url|dir>",
+		Short: "Add a repository, syncing it first to check it works",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+
+			requireRoot() // ensure running as root
+
+			repo := RepoConfig{
+				Name:     args[0],
+				Type:     repoType,
+				URL:      args[1],
+				Ref:      branch,
+				Priority: priority,
+				Signed:   signedRepo,
+			}
+
+			if err := addRepo(repo); err != nil {
+				eyes.Fatalf("Failed to add repository: %v", err)
+			}
+		},
+	}
+
+	repoRemoveCmd := &cobra.Command{
+		Use:     "remove <name>",
+		Short:   "Remove a repository and its clone",
+		Args:    cobra.ExactArgs(1),
+		Aliases: []string{"rm"},
+		Run: func(cmd *cobra.Command, args []string) {
+
+			requireRoot() // ensure running as root
+
+			if err := removeRepo(args[0]); err != nil {
+				eyes.Fatalf("Failed to remove repository: %v", err)
+			}
+		},
+	}
+
+	repoListCmd := &cobra.Command{
+		Use:     "list",
+		Short:   "List repositories with their last sync",
+		Args:    cobra.NoArgs,
+		Aliases: []string{"ls"},
+		Run: func(cmd *cobra.Command, args []string) {
+
+			if err := listRepos(jsonOutput); err != nil {
+				eyes.Fatalf("Failed to list repositories: %v", err)
+			}
+		},
+	}
+
+	repoEnableCmd := &cobra.Command{
+		Use:   "enable <name>",
+		Short: "Sync and resolve packages from a repository again",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+
+			requireRoot() // ensure running as root
+
+			if err := setRepoEnabled(args[0], true); err != nil {
+				eyes.Fatalf("Failed to enable repository: %v", err)
+			}
+		},
+	}
+
+	repoDisableCmd := &cobra.Command{
+		Use:   "disable <name>",
+		Short: "Keep a repository configured, but stop syncing and resolving from it",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+
+			requireRoot() // ensure running as root
+
+			if err := setRepoEnabled(args[0], false); err != nil {
+				eyes.Fatalf("Failed to disable repository: %v", err)
+			}
+		},
+	}
+
+	repoSignCmd := &cobra.Command{
+		Use:   "sign <dir>",
+		Short: "Index a repository checkout and sign the index (for maintainers)",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+
+			if keyFile == "" {
+				eyes.Fatalf("--key is required")
+			}
+
+			if err := signRepo(args[0], keyFile); err != nil {
+				eyes.Fatalf("Failed to sign %s: %v", args[0], err)
+			}
+		},
+	}
+
+	repoPublishCmd := &cobra.Command{
+		Use:   "publish <dir>",
+		Short: "Write an http repository (INDEX.gz, recipes.tar.gz) for the recipes in dir",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+
+			if output == "" {
+				output = filepath.Join(args[0], "public")
+			}
+
+			if err := publishRepo(args[0], output, keyFile); err != nil {
+				eyes.Fatalf("Failed to publish %s: %v", args[0], err)
+			}
+		},
+	}
+
+	repoCmd.AddCommand(repoAddCmd, repoRemoveCmd, repoListCmd, repoEnableCmd, repoDisableCmd, repoSignCmd, repoPublishCmd)
+
+	/****************************************************/
+	// blink key add/list/remove/generate
+	// keys that repository indexes may be signed with
+	/****************************************************/
+	keyCmd := &cobra.Command{
+		Use:     "key",
+		Short:   "Manage trusted repository signing keys",
+		Aliases: []string{"keys"},
+	}
+
+	keyAddCmd := &cobra.Command{
+		Use:   "add <name> <file|key>",
+		Short: "Trust a repository signing key",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+
+			requireRoot() // ensure running as root
+
+			if err := addTrustedKey(args[0], args[1]); err != nil {
+				eyes.Fatalf("Failed to add key: %v", err)
+			}
+		},
+	}
+
+	keyListCmd := &cobra.Command{
+		Use:     "list",
+		Short:   "List trusted keys",
+		Args:    cobra.NoArgs,
+		Aliases: []string{"ls"},
+		Run: func(cmd *cobra.Command, args []string) {
+
+			if err := listTrustedKeys(jsonOutput); err != nil {
+				eyes.Fatalf("Failed to list keys: %v", err)
+			}
+		},
+	}
+
+	keyRemoveCmd := &cobra.Command{
+		Use:     "remove <name>",
+		Short:   "Stop trusting a key",
+		Args:    cobra.ExactArgs(1),
+		Aliases: []string{"rm"},
+		Run: func(cmd *cobra.Command, args []string) {
+
+			requireRoot() // ensure running as root
+
+			if err := removeTrustedKey(args[0]); err != nil {
+				eyes.Fatalf("Failed to remove key: %v", err)
+			}
+		},
+	}
+
+	keyGenerateCmd := &cobra.Command{
+		Use:     "generate <name>",
+		Short:   "Create a signing key pair in the current directory (for maintainers)",
+		Args:    cobra.ExactArgs(1),
+		Aliases: []string{"gen"},
+		Run: func(cmd *cobra.Command, args []string) {
+
+			if err := generateKeyPair(args[0]); err != nil {
+				eyes.Fatalf("Failed to generate key: %v", err)
+			}
+		},
+	}
+
+	keyCmd.AddCommand(keyAddCmd, keyListCmd, keyRemoveCmd, keyGenerateCmd)
+
+	/****************************************************/
+	// Lint command for validating recipes, meant for
+	// repository CI, so it doesn't need root
+	/****************************************************/
+	lintCmd := &cobra.Command{
+		Use:     "lint <file|dir>",
+		Short:   "Validate package recipes (JSON files)",
+		Args:    cobra.ExactArgs(1),
+		Aliases: []string{"check", "validate"},
+		Run: func(cmd *cobra.Command, args []string) {
+
+			issues, err := lintRecipes(args[0])
+			if err != nil {
+				eyes.Fatalf("Failed to lint %s: %v", args[0], err)
+			}
+
+			errors := 0
+			for _, issue := range issues {
+				fmt.Println(issue)
+				if !issue.Warning {
+					errors++
+				}
+			}
+
+			if errors > 0 {
+				eyes.Fatalf("%d error(s), %d warning(s) found.", errors, len(issues)-errors)
+			}
+			eyes.Successf("No errors found (%d warning(s)).", len(issues))
+		},
+	}
+
 	/****************************************************/
 	// Support command for displaying support information
 	/****************************************************/
@@ -346,22 +887,51 @@ func main() {
 	// Add flags to commands
 	/****************************************************/
 
+	rootCmd.PersistentFlags().StringVar(&root, "root", "/", "Install into another root filesystem (manifest, lock and cache move with it)")
 	getCmd.Flags().BoolVarP(&force, "force", "f", false, "Force re-download")
-	getCmd.Flags().StringVarP(&path, "path", "p", defaultCachePath, "Specify recipes directory")
+	getCmd.Flags().StringVarP(&path, "path", "p", "", "Specify cache directory (default: Blink's cache path)")
 	infoCmd.Flags().BoolVarP(&force, "force", "f", false, "Force re-download")
-	infoCmd.Flags().StringVarP(&path, "path", "p", defaultCachePath, "Specify recipes directory")
+	infoCmd.Flags().StringVarP(&path, "path", "p", "", "Specify cache directory (default: Blink's cache path)")
+	searchCmd.Flags().BoolVarP(&useRegex, "regex", "r", false, "Treat the query as a regular expression")
+	searchCmd.Flags().BoolVarP(&useFuzzy, "fuzzy", "z", false, "Also match names whose letters contain the query in order")
+	searchCmd.Flags().BoolVar(&jsonOutput, "json", false, "Print results as JSON")
 	installCmd.Flags().BoolVarP(&force, "force", "f", false, "Force reinstall")
-	installCmd.Flags().StringVarP(&path, "path", "p", defaultCachePath, "Specify recipes directory")
+	installCmd.Flags().StringVarP(&path, "path", "p", "", "Specify cache directory (default: Blink's cache path)")
+	installCmd.Flags().StringArrayVar(&overwriteGlobs, "overwrite", nil, "Overwrite conflicting files matching this glob (repeatable)")
 	uninstallCmd.Flags().BoolVarP(&force, "force", "f", false, "Force uninstall")
-	uninstallCmd.Flags().StringVarP(&path, "path", "p", defaultCachePath, "Specify recipes directory")
+	uninstallCmd.Flags().StringVarP(&path, "path", "p", "", "Specify cache directory (default: Blink's cache path)")
+	uninstallCmd.Flags().BoolVar(&cascade, "cascade", false, "Also uninstall packages that depend on it")
+	uninstallCmd.Flags().BoolVar(&nodeps, "nodeps", false, "Uninstall even if other packages depend on it (breaks them)")
 	syncCmd.Flags().BoolVarP(&force, "force", "f", false, "Force re-sync")
+	syncCmd.Flags().BoolVar(&lockRepos, "lock", false, "Write the revision of every repository to the lock file")
+	syncCmd.Flags().BoolVar(&frozen, "frozen", false, "Sync every repository to the revision in the lock file")
+	syncCmd.Flags().StringVar(&lockFile, "lock-file", "", "Lock file to write or read (default: etc/repos.lock in Blink's cache path)")
+	ownsCmd.Flags().BoolVar(&jsonOutput, "json", false, "Print results as JSON")
+	filesCmd.Flags().BoolVar(&jsonOutput, "json", false, "Print results as JSON")
+	verifyCmd.Flags().BoolVar(&configOK, "config-ok", false, "Skip files marked as config")
+	autoremoveCmd.Flags().BoolVarP(&dryRun, "dry-run", "n", false, "Only list orphaned packages")
+	autoremoveCmd.Flags().StringVarP(&path, "path", "p", "", "Specify cache directory (default: Blink's cache path)")
+	updateCmd.Flags().StringArrayVar(&overwriteGlobs, "overwrite", nil, "Overwrite conflicting files matching this glob (repeatable)")
+	generationsCmd.Flags().BoolVar(&jsonOutput, "json", false, "Print results as JSON")
+	rollbackCmd.Flags().StringVarP(&path, "path", "p", "", "Specify cache directory (default: Blink's cache path)")
+	rollbackCmd.Flags().StringArrayVar(&overwriteGlobs, "overwrite", nil, "Overwrite conflicting files matching this glob (repeatable)")
+	repoAddCmd.Flags().StringVarP(&branch, "branch", "b", "main", "Branch to track")
+	repoAddCmd.Flags().IntVar(&priority, "priority", 0, "Resolution priority, higher wins when several repositories have a package")
+	repoListCmd.Flags().BoolVar(&jsonOutput, "json", false, "Print results as JSON")
+	repoAddCmd.Flags().StringVarP(&repoType, "type", "t", repoTypeGit, "Repository type: git, http or local (a directory, read in place)")
+	repoAddCmd.Flags().BoolVar(&signedRepo, "signed", false, "Refuse the repository unless its index is signed by a trusted key")
+	repoSignCmd.Flags().StringVarP(&keyFile, "key", "k", "", "Private key file (from 'blink key generate')")
+	repoPublishCmd.Flags().StringVarP(&keyFile, "key", "k", "", "Sign the index with this private key")
+	repoPublishCmd.Flags().StringVarP(&output, "output", "o", "", "Output directory (default: <dir>/public)")
+	keyListCmd.Flags().BoolVar(&jsonOutput, "json", false, "Print results as JSON")
 
 	// Add commands to cobra cli root command
-	rootCmd.AddCommand(getCmd, infoCmd, installCmd, supportCmd, versionCmd, cleanCmd, completionCmd, syncCmd, uninstallCmd, updateCmd)
+	rootCmd.AddCommand(getCmd, infoCmd, searchCmd, installCmd, supportCmd, versionCmd, cleanCmd, completionCmd, syncCmd, uninstallCmd, updateCmd, lintCmd, ownsCmd, filesCmd, verifyCmd, autoremoveCmd, holdCmd, unholdCmd, generationsCmd, rollbackCmd, repoCmd, keyCmd)
 
-	// Print welcome message
-	fmt.Printf("Blink Package Manager Version: %s\n", Version)
-	fmt.Printf("© Copyright 2025-%d Aperture OS. All rights reserved.\n", currentYear)
+	// Print welcome message, on stderr so --json output and
+	// completion scripts on stdout stay machine readable
+	fmt.Fprintf(os.Stderr, "Blink Package Manager Version: %s\n", Version)
+	fmt.Fprintf(os.Stderr, "© Copyright 2025-%d Aperture OS. All rights reserved.\n", currentYear)
 
 	// Execute root command
 	if err := fang.Execute(context.Background(), rootCmd, fang.WithoutVersion(), fang.WithColorSchemeFunc(colorScheme)); err != nil {
@@ -27,14 +27,24 @@
 package main
 
 import (
+	"bytes"
+	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/BurntSushi/toml"
 
 	"github.com/Aperture-OS/eyes"
 )
 
+// manifestSchema is the current manifest layout version
+// 1 = name, version, release (no schema key at all)
+// 2 = epoch, install reason, timestamps, source repo/commit, optional deps
+// 3 = package holds
+// 4 = downgraded marker
+const manifestSchema = 4
+
 // ensureManifest makes sure the manifest file exists and creates it if it doesn't
 func ensureManifest() error {
 	eyes.Infof("Ensuring manifest exists at %s", manifestPath)
@@ -44,7 +54,7 @@ func ensureManifest() error {
 	}
 
 	if _, err := os.Stat(manifestPath); os.IsNotExist(err) {
-		m := Manifest{Installed: []InstalledPkg{}}
+		m := Manifest{Schema: manifestSchema, Installed: []InstalledPkg{}}
 		file, err := os.Create(manifestPath)
 		if err != nil {
 			return err
@@ -63,19 +73,76 @@ func loadManifest() (Manifest, error) {
 	eyes.Infof("Loading manifest")
 
 	var m Manifest
-	if _, err := os.Stat(manifestPath); os.IsNotExist(err) {
-		return Manifest{Installed: []InstalledPkg{}}, nil
+	info, err := os.Stat(manifestPath)
+	if os.IsNotExist(err) {
+		return Manifest{Schema: manifestSchema, Installed: []InstalledPkg{}}, nil
 	}
 
 	if _, err := toml.DecodeFile(manifestPath, &m); err != nil {
 		return m, err
 	}
 
+	if m.Schema > manifestSchema {
+		return m, fmt.Errorf("manifest schema %d is newer than this Blink supports (%d), please update Blink", m.Schema, manifestSchema)
+	}
+
+	if m.Schema < manifestSchema {
+		old := m.Schema
+		if old == 0 {
+			old = 1 // schema 1 had no schema key
+		}
+		migrateManifest(&m, info.ModTime())
+
+		// keep the old file around, then persist. read only commands
+		// (owns, files...) may run without root, they just migrate in memory
+		backup := fmt.Sprintf("%s.schema%d.bak", manifestPath, old)
+		if err := copyFileAtomic(manifestPath, backup, 0644); err != nil {
+			eyes.Warnf("Migrated manifest in memory only, could not back it up: %v", err)
+		} else if err := saveManifest(m); err != nil {
+			eyes.Warnf("Migrated manifest in memory only, could not save it: %v", err)
+		} else {
+			eyes.Infof("Migrated manifest from schema %d to %d (backup at %s)", old, manifestSchema, backup)
+		}
+	}
+
 	return m, nil
 }
 
 /****************************************************/
-// saveManifest writes the manifest back to disk safely
+// migrateManifest upgrades an older manifest to manifestSchema in place
+// every step only fills in what the older schema didn't have
+/****************************************************/
+func migrateManifest(m *Manifest, modTime time.Time) {
+	// schema 1 had no schema key, so it decodes as 0
+	if m.Schema < 2 {
+		for i := range m.Installed {
+			p := &m.Installed[i]
+
+			// we can't know, explicit is the safe guess: autoremove never touches it
+			if p.Reason == "" {
+				p.Reason = reasonExplicit
+			}
+
+			// best we have is when the manifest was last written
+			if p.InstalledAt.IsZero() {
+				p.InstalledAt = modTime
+			}
+			if p.UpdatedAt.IsZero() {
+				p.UpdatedAt = modTime
+			}
+		}
+	}
+
+	// schema 3 only added the optional holds list, schema 4 the
+	// downgraded marker, which defaults to false. nothing to fill in
+
+	m.Schema = manifestSchema
+}
+
+/****************************************************/
+// saveManifest writes the manifest back to disk safely: tmp file,
+// fsync, rename over the old one, fsync the directory. a crash leaves
+// either the old or the new manifest, never half of one
 /****************************************************/
 func saveManifest(m Manifest) error {
 	eyes.Infof("Saving manifest (%d packages)", len(m.Installed))
@@ -84,70 +151,132 @@ func saveManifest(m Manifest) error {
 		return err
 	}
 
+	m.Schema = manifestSchema
 	tmp := manifestPath + ".tmp"
 
-	file, err := os.Create(tmp)
-	if err != nil {
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(m); err != nil {
 		return err
 	}
-	defer file.Close()
 
-	if err := toml.NewEncoder(file).Encode(m); err != nil {
+	if err := writeFileSync(tmp, buf.Bytes()); err != nil {
 		return err
 	}
 
-	return os.Rename(tmp, manifestPath)
+	if err := os.Rename(tmp, manifestPath); err != nil {
+		return err
+	}
+
+	return syncDir(filepath.Dir(manifestPath))
 }
 
 // manifestHas checks if a package is already in the manifest
 func manifestHas(name string) (*InstalledPkg, bool, error) {
-	m, err := loadManifest()
+	db, err := packageDB()
 	if err != nil {
 		return nil, false, err
 	}
 
-	for _, p := range m.Installed {
-		if p.Name == name {
-			return &p, true, nil
-		}
+	p, ok := db.Get(name)
+	if !ok {
+		return nil, false, nil
 	}
 
-	return nil, false, nil
+	found := *p // a copy, callers only read it
+	return &found, true, nil
 }
 
 /****************************************************/
 // isInstalled checks if a package is installed by name
 /****************************************************/
 func isInstalled(pkg string) bool {
-	_, ok, err := manifestHas(pkg)
-	return err == nil && ok
+	db, err := packageDB()
+	return err == nil && db.Has(pkg)
 }
 
 /****************************************************/
-// addToManifest adds a package to the manifest if it doesn't already exist
+// addToManifest records an install in the manifest. a package that is
+// already there (reinstall/update) gets its version, update time, origin
+// and optional dependency choices refreshed, its install time is kept.
+// the reason only ever gets promoted: installing a dependency explicitly
+// makes it explicit, updating it as a dependency doesn't demote it.
+// installing an older version than the recorded one marks it downgraded
 /****************************************************/
-func addToManifest(pkg PackageInfo) error {
+func addToManifest(pkg PackageInfo, reason string, optDeps []OptSelection) error {
 	eyes.Infof("adding %s to manifest", pkg.Name)
 
-	m, err := loadManifest()
+	db, err := packageDB()
 	if err != nil {
 		return err
 	}
 
-	for _, p := range m.Installed {
-		if p.Name == pkg.Name {
-			eyes.Warnf("%s already recorded in manifest", pkg.Name)
-			return nil
+	now := time.Now()
+	repo, commit := recipeOrigin(pkg)
+
+	if reason == "" {
+		reason = reasonExplicit
+	}
+
+	if p, ok := db.Get(pkg.Name); ok {
+		eyes.Infof("%s already recorded in manifest, updating entry", pkg.Name)
+
+		// going backwards marks a downgrade, going forwards clears it,
+		// a reinstall of the same version keeps whatever it was
+		switch cmp := compareEVR(pkg.evr(), p.evr()); {
+		case cmp < 0:
+			p.Downgraded = true
+		case cmp > 0:
+			p.Downgraded = false
+		}
+
+		p.Epoch = pkg.Epoch
+		p.Version = pkg.Version
+		p.Release = int64(pkg.Release)
+		p.UpdatedAt = now
+		p.Repo = repo
+		p.Commit = commit
+		p.OptDeps = optDeps
+		if reason == reasonExplicit {
+			p.Reason = reasonExplicit
 		}
+		return db.Save()
 	}
 
-	m.Installed = append(m.Installed, InstalledPkg{
-		Name:    pkg.Name,
-		Version: pkg.Version,
-		Release: int64(pkg.Release),
+	db.Put(InstalledPkg{
+		Name:        pkg.Name,
+		Epoch:       pkg.Epoch,
+		Version:     pkg.Version,
+		Release:     int64(pkg.Release),
+		Reason:      reason,
+		InstalledAt: now,
+		UpdatedAt:   now,
+		Repo:        repo,
+		Commit:      commit,
+		OptDeps:     optDeps,
 	})
 
-	return saveManifest(m)
+	return db.Save()
+}
+
+/****************************************************/
+// setManifestOrigin overrides the repository and commit recorded for
+// an installed package, for recipes that didn't come from the
+// repository HEAD (versioned installs)
+/****************************************************/
+func setManifestOrigin(name string, repo string, commit string) error {
+	db, err := packageDB()
+	if err != nil {
+		return err
+	}
+
+	p, ok := db.Get(name)
+	if !ok {
+		return fmt.Errorf("package %s not found in manifest", name)
+	}
+
+	p.Repo = repo
+	p.Commit = commit
+	return db.Save()
 }
 
 /****************************************************/
@@ -156,27 +285,15 @@ func addToManifest(pkg PackageInfo) error {
 func removeFromManifest(pkg PackageInfo) error {
 	eyes.Infof("removing %s from manifest", pkg.Name)
 
-	m, err := loadManifest()
+	db, err := packageDB()
 	if err != nil {
 		return err
 	}
 
-	found := false
-	newInstalled := make([]InstalledPkg, 0, len(m.Installed))
-
-	for _, p := range m.Installed {
-		if p.Name == pkg.Name {
-			found = true
-			continue // skip the package we want to remove
-		}
-		newInstalled = append(newInstalled, p)
-	}
-
-	if !found {
+	if !db.Remove(pkg.Name) {
 		eyes.Warnf("%s not found in manifest", pkg.Name)
 		return nil
 	}
 
-	m.Installed = newInstalled
-	return saveManifest(m)
+	return db.Save()
 }
@@ -21,7 +21,7 @@
 package main
 
 import (
-	"io"
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -57,10 +57,6 @@ func getpkg(pkgName string, path string) error {
 		path += string(os.PathSeparator)
 	}
 
-	repos, err := LoadRepos(configPath)
-	if err != nil {
-	}
-
 	// make sure cache directories exist
 	checkDirAndCreate(filepath.Join(path, "recipes"))
 
@@ -69,27 +65,23 @@ func getpkg(pkgName string, path string) error {
 		return fmt.Errorf("failed to update repository: %v", err)
 	}
 
-	repo, wasRepoFound := FindRepoByName(pkgName, repos)
-
-	if !wasRepoFound {
-		eyes.Errorf("Repository not found for package %s", pkgName)
+	// "pkg" or "repo/pkg", see resolveRecipe
+	repo, name, err := resolveRecipe(pkgName)
+	if err != nil {
+		eyes.Errorf("%v", err)
+		return err
 	}
 
-	destPath := filepath.Join(path, "recipes", pkgName+".json")
+	destPath := filepath.Join(path, "recipes", name+".json")
 
 	// handle --force behavior: overwrite if exists
 	if _, err := os.Stat(destPath); err == nil {
 		eyes.Warnf("Recipe %s already exists, overwriting...", destPath)
 	}
 
-	srcPath := filepath.Join(
-		repoCachePath,
-		repo.Name,
-		pkgName+".json",
-	)
-
-	// copy recipe from local repo cache
-	input, err := os.ReadFile(srcPath)
+	// copy recipe from local repo cache, signed repositories get
+	// checked against their index first (see signing.go)
+	input, err := readRecipe(repo, name)
 	if err != nil {
 		return fmt.Errorf("failed to read package from repo cache: %v", err)
 	}
@@ -98,12 +90,17 @@ func getpkg(pkgName string, path string) error {
 		return fmt.Errorf("failed to write package to cache: %v", err)
 	}
 
-	eyes.Infof("Package %s copied to %s", pkgName, destPath)
+	eyes.Infof("Package %s copied from repository %s to %s", name, repo.Name, destPath)
 	return nil
 }
 
+// presetRecipes holds recipes picked ahead of time (an older version,
+// a generation's), fetchpkg returns them instead of the repositories'
+// so dependency resolution sees them too, without touching the cache
+var presetRecipes map[string]PackageInfo
+
 /****************************************************/
-// fetchPkg fetches a package recipe from cache or repository, decodes it, and displays package info
+// fetchPkg fetches a package recipe from the synced repositories, decodes it, and displays package info
 // in addition, it returns the PackageInfo struct for further use, so you can use this function to both
 // get the struct and show the info to the user, avoiding code repetition and enhancing modularity
 // avoids 2 functions for fetching and displaying info separately
@@ -119,69 +116,88 @@ func fetchpkg(path string, force bool, pkgName string, quiet bool) (PackageInfo,
 		path += string(os.PathSeparator)
 	}
 
-	recipePath := filepath.Join(path, "recipes", pkgName+".json")
-
-	if force {
-		if err := os.Remove(recipePath); err == nil {
-			if !quiet {
-				eyes.Infof("Force flag detected, removed cached recipe at %s", recipePath)
-			}
-		} else if !os.IsNotExist(err) {
-			if !quiet {
-				eyes.Warnf("Failed to remove cached recipe.\nERR: %v", err)
-			}
-		}
+	// installing an older version or rolling back, see presetRecipes
+	_, name := splitRepoSpec(pkgName)
+	if pkg, ok := presetRecipes[name]; ok {
+		return pkg, nil
 	}
 
-	if _, err := os.Stat(recipePath); os.IsNotExist(err) {
-		if !quiet {
-			eyes.Infof("Package recipe not found. Downloading...")
+	// the repositories decide on every fetch, priorities, overlays and
+	// syncs all change which recipe wins. recipes/ only mirrors the
+	// result for 'blink get', it's never read back here
+	repo, name, err := resolveRecipe(pkgName)
+	if err != nil || force {
+		if !quiet && force {
+			eyes.Infof("Force flag detected, syncing repositories...")
+		} else if !quiet {
+			eyes.Infof("Package recipe not found in synced repositories. Syncing...")
 		}
 		if err := getpkg(pkgName, path); err != nil {
 			return PackageInfo{}, err
 		}
+		if repo, name, err = resolveRecipe(pkgName); err != nil {
+			return PackageInfo{}, err
+		}
 	}
 
-	f, err := os.Open(recipePath)
+	// local overlays are read in place, signed repositories get checked
+	// against their index (see signing.go)
+	data, err := readRecipe(repo, name)
 	if err != nil {
-		eyes.Fatalf("Failed to open package recipe.\nERR: %v", err)
-		return PackageInfo{}, fmt.Errorf("error opening file: %v", err)
+		return PackageInfo{}, fmt.Errorf("failed to read package %s from repository %s: %v", name, repo.Name, err)
+	}
+
+	if repo.Type != repoTypeLocal {
+		recipePath := filepath.Join(path, "recipes", name+".json")
+		if cached, err := os.ReadFile(recipePath); err != nil || !bytes.Equal(cached, data) {
+			checkDirAndCreate(filepath.Join(path, "recipes"))
+			if err := os.WriteFile(recipePath, data, 0644); err != nil && !quiet {
+				eyes.Warnf("Failed to update cached recipe at %s.\nERR: %v", recipePath, err)
+			}
+		}
 	}
-	defer f.Close()
 
 	var pkg PackageInfo
-	if err := json.NewDecoder(f).Decode(&pkg); err != nil {
-		eyes.Fatalf("Failed to parse JSON.\nERR: %v", err)
-		return PackageInfo{}, fmt.Errorf("error decoding JSON: %v", err)
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return PackageInfo{}, fmt.Errorf("failed to parse recipe %s from repository %s: %v", name, repo.Name, err)
 	}
 
 	if !quiet {
 
-		repos, err := LoadRepos(configPath)
+		// which repository won, and which ones it won against
+		providers, err := repoProviders(pkg.Name)
 		if err != nil {
-			return PackageInfo{}, fmt.Errorf("repositories could not be loaded.")
+			return PackageInfo{}, fmt.Errorf("repositories could not be loaded: %v", err)
 		}
 
-		// ensure repository is cloned/pulled
-		if err := ensureRepo(false); err != nil {
-			return PackageInfo{}, fmt.Errorf("failed to update repository: %v", err)
+		origin := fmt.Sprintf("%s (%s, priority %d)", repo.Name, describeRepo(repo), repo.Priority)
+		if repo.Type == repoTypeLocal {
+			origin = fmt.Sprintf("%s (%s)", repo.Name, describeRepo(repo)) // overlays always come first
 		}
-
-		repo, wasRepoFound := FindRepoByName(pkgName, repos)
-
-		if !wasRepoFound {
-			eyes.Errorf("Repository not found for package %s", pkgName)
+		var others []string
+		for _, p := range providers {
+			if p.Name == repo.Name {
+				continue
+			} else if p.Type == repoTypeLocal {
+				others = append(others, fmt.Sprintf("%s (local overlay)", p.Name))
+			} else {
+				others = append(others, fmt.Sprintf("%s (priority %d)", p.Name, p.Priority))
+			}
+		}
+		if len(others) > 0 {
+			origin += "\nAlso in:     " + strings.Join(others, ", ")
 		}
 
-		fmt.Printf(`Repository: %q (%s)
+		fmt.Printf(`Repository:  %s
 Name:        %s
+Epoch:       %d
 Version:     %s
 Release:     %d
 Description: %s
 Author:      %s
 License:     %s
 
-`, repo.Name, repo.URL, pkg.Name, pkg.Version, pkg.Release, pkg.Description, pkg.Author, pkg.License)
+`, origin, pkg.Name, pkg.Epoch, pkg.Version, pkg.Release, pkg.Description, pkg.Author, pkg.License)
 
 		eyes.Infof("Package fetching completed.")
 	}
@@ -194,9 +210,10 @@ License:     %s
 // it fetches package info, downloads source, decompresses it
 // it uses the getSource, decompressSource functions for modularity and to satisfy my KISS principle
 // i wish golang had macros so i could avoid writing the same error handling code every single time and just have a single line for it
+// reason is recorded in the manifest (reasonExplicit or reasonDependency)
 /****************************************************/
 
-func install(pkgName string, force bool, path string) error {
+func install(pkgName string, force bool, path string, reason string) error {
 	// manifest must exist BEFORE touching it
 	if err := ensureManifest(); err != nil {
 		return err
@@ -208,22 +225,70 @@ func install(pkgName string, force bool, path string) error {
 		return err
 	}
 
+	return installRecipe(pkg, force, path, reason)
+}
+
+/****************************************************/
+// upgrade reinstalls an installed package from the repositories as they
+// are, without syncing them again (update already did), keeping its
+// recorded optional dependency choices and install reason
+/****************************************************/
+func upgrade(pkgName string, path string) error {
+	inst, exists, err := manifestHas(pkgName)
+	if err != nil {
+		return err
+	}
+
+	pkg, err := fetchpkg(path, false, pkgName, false)
+	if err != nil {
+		return err
+	}
+
+	// a choice made ahead of time (rollback) still wins
+	if exists {
+		if presetOptDeps == nil {
+			presetOptDeps = make(map[string][]OptSelection)
+			defer func() { presetOptDeps = nil }()
+		}
+		if _, ok := presetOptDeps[pkg.Name]; !ok {
+			presetOptDeps[pkg.Name] = inst.OptDeps
+			defer delete(presetOptDeps, pkg.Name)
+		}
+	}
+
+	return installRecipe(pkg, true, path, reasonDependency) // keeps the recorded reason
+}
+
+/****************************************************/
+// installRecipe is install without the fetching, it builds and installs
+// exactly the recipe it's given. dependency resolution goes through
+// fetchpkg, so a recipe that isn't the repositories' one has to be in
+// presetRecipes too (see installVersion)
+/****************************************************/
+func installRecipe(pkg PackageInfo, force bool, path string, reason string) error {
 	installed, exists, err := manifestHas(pkg.Name)
 	if err != nil {
 		return err
 	}
 
+	// holds win over everything, even --force
+	db, err := packageDB()
+	if err != nil {
+		return err
+	}
+	if err := checkHold(db.Manifest, pkg); err != nil {
+		return err
+	}
+
 	if exists && !force {
-		eyes.Errorf("Package %s is already installed (version=%s release=%d). Use --force to reinstall.",
+		eyes.Errorf("Package %s is already installed (%s). Use --force to reinstall.",
 			installed.Name,
-			installed.Version,
-			installed.Release,
+			installed.evr(),
 		)
 		return fmt.Errorf(
-			"package %s already installed (version=%s release=%d)",
+			"package %s already installed (%s)",
 			installed.Name,
-			installed.Version,
-			installed.Release,
+			installed.evr(),
 		)
 	}
 
@@ -233,7 +298,8 @@ func install(pkgName string, force bool, path string) error {
 	}
 
 	// optional deps
-	if err := handleOptionalDeps(pkg.Name, path); err != nil {
+	optDeps, err := handleOptionalDeps(pkg.Name, path)
+	if err != nil {
 		return err
 	}
 
@@ -241,7 +307,7 @@ func install(pkgName string, force bool, path string) error {
 
 	switch packageKind {
 
-	case "toCompile":
+	case "tocompile":
 
 		// prepare build root
 		if err := os.MkdirAll(buildRoot, 0755); err != nil {
@@ -298,15 +364,28 @@ func install(pkgName string, force bool, path string) error {
 			}
 		}
 
-		// install
+		// install into the staging root, recipes must honor $DESTDIR
+		stageDir, err := prepareStage(pkg.Name)
+		if err != nil {
+			return err
+		}
+		os.Setenv("DESTDIR", stageDir)
+		defer os.Unsetenv("DESTDIR")
+
 		for _, cmd := range pkg.Build.Install {
-			eyes.Infof("Installing package.")
+			eyes.Infof("Installing package into staging root %s.", stageDir)
 			if err := runCmd("sh", "-c", cmd); err != nil {
 				return err
 			}
 		}
 
-	case "preCompiled":
+		// merge the staged tree into / and remember every file
+		if err := stageAndRecord(pkg, stageDir, installRoot); err != nil {
+			return err
+		}
+		_ = os.RemoveAll(stageDir)
+
+	case "precompiled":
 		eyes.Infof("Installing precompiled package %s", pkg.Name)
 
 		// prepare build root
@@ -355,58 +434,16 @@ func install(pkgName string, force bool, path string) error {
 			return err
 		}
 
-		// default behavior: copy filesystem layout to /
-		err = filepath.Walk(extractRoot, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		rel, err := filepath.Rel(extractRoot, path)
-		if err != nil {
-			return err
-		}
-		if rel == "." {
-			return nil
-		}
-
-		target := filepath.Join("/", rel)
-
-		// directories
-		if info.IsDir() {
-			return os.MkdirAll(target, info.Mode())
-		}
-
-		// skip symlinks (important for package installs)
-		if info.Mode()&os.ModeSymlink != 0 {
-			return nil
-		}
-
-		// ensure parent dir exists
-		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
-			return err
-		}
-
-		in, err := os.Open(path)
-		if err != nil {
+		// default behavior: the archive IS the filesystem layout,
+		// merge it into / and remember every file
+		if err := stageAndRecord(pkg, extractRoot, installRoot); err != nil {
 			return err
 		}
-		defer in.Close()
 
-		out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode())
-		if err != nil {
-			return err
-		}
-		defer out.Close()
-
-		_, err = io.Copy(out, in)
-		return err
-	})
-
-	if err != nil {
-		return err
-	}
+		// optional post-install commands, they act on the target root
+		os.Setenv("DESTDIR", installRoot)
+		defer os.Unsetenv("DESTDIR")
 
-		// optional post-install commands
 		for _, cmd := range pkg.Build.Install {
 			if err := runCmd("sh", "-c", cmd); err != nil {
 				return err
@@ -417,8 +454,11 @@ func install(pkgName string, force bool, path string) error {
 		return fmt.Errorf("unknown build kind: %s", pkg.Build.Kind)
 	}
 
-	// record install
-	if err := addToManifest(pkg); err != nil {
+	// record install, with the exact recipe it came from
+	if err := saveRecipeDB(pkg); err != nil {
+		return err
+	}
+	if err := addToManifest(pkg, reason, optDeps); err != nil {
 		return err
 	}
 	return nil
@@ -433,6 +473,10 @@ func install(pkgName string, force bool, path string) error {
 
 /****************************************************/
 // uninstall uninstalls a package
+// it removes exactly the files recorded in the package's file database
+// at install time, no source download, no extraction, works offline.
+// the recipe's build.uninstall commands are an optional pre-remove hook,
+// taken from the recipe recorded at install time (see localRecipe)
 /****************************************************/
 
 func uninstall(pkgName string, force bool, path string) error {
@@ -441,13 +485,7 @@ func uninstall(pkgName string, force bool, path string) error {
 		return err
 	}
 
-	// fetch recipe
-	pkg, err := fetchpkg(path, force, pkgName, false)
-	if err != nil {
-		return err
-	}
-
-	_, exists, err := manifestHas(pkg.Name)
+	_, exists, err := manifestHas(pkgName)
 	if err != nil {
 		return err
 	}
@@ -457,74 +495,123 @@ func uninstall(pkgName string, force bool, path string) error {
 		return fmt.Errorf("package %s doesn't exist.", pkgName)
 	}
 
-	// prepare build root
-	if err := os.MkdirAll(buildRoot, 0755); err != nil {
+	db, err := loadFileDB(pkgName)
+	if err == os.ErrNotExist {
+		if !force {
+			eyes.Errorf("Package %s has no file database (installed before file tracking). Reinstall it with --force first (files still identical to the package are adopted, changed ones need --overwrite), or uninstall with --force to only forget it.", pkgName)
+			return fmt.Errorf("no file database for %s", pkgName)
+		}
+		eyes.Warnf("Package %s has no file database, its files will be left on the system.", pkgName)
+	} else if err != nil {
 		return err
 	}
 
-	extractRoot := filepath.Join(buildRoot, pkg.Name)
+	// pre-remove hook
+	if pkg, ok := localRecipe(path, pkgName); ok && len(pkg.Build.Uninstall) > 0 {
+		for k, v := range pkg.Build.Env {
+			eyes.Infof("Setting environment variables.")
+			os.Setenv(k, v)
+		}
+		os.Setenv("DESTDIR", installRoot)
+		defer os.Unsetenv("DESTDIR")
 
-	_ = os.RemoveAll(extractRoot)
-	if err := os.MkdirAll(extractRoot, 0755); err != nil {
-		return err
+		for _, cmd := range pkg.Build.Uninstall {
+			eyes.Infof("Running pre-remove hook.")
+			if err := runCmd("sh", "-c", cmd); err != nil {
+				if !force {
+					return fmt.Errorf("pre-remove hook failed (use --force to ignore): %v", err)
+				}
+				eyes.Warnf("Pre-remove hook failed, continuing because of --force: %v", err)
+			}
+		}
 	}
 
-	// download source
-	if err := getSource(pkg.Source.URL, force); err != nil {
-		return err
+	// remove recorded files
+	eyes.Infof("Removing %d recorded files of %s", len(db.Files), pkgName)
+	if err := removeRecordedFiles(db.Files, installRoot); err != nil {
+		if !force {
+			return err
+		}
+		eyes.Warnf("%v", err)
 	}
 
-	srcFile := filepath.Join(sourcePath, filepath.Base(pkg.Source.URL))
-	ok, err := compareSHA256(pkg.Source.Sha256, srcFile)
-	if err != nil {
+	if err := removeFileDB(pkgName); err != nil {
 		return err
 	}
-	if !ok {
-		eyes.Errorf("Source hash mismatch for %s", srcFile)
-		return fmt.Errorf("source hash mismatch for %s", srcFile)
-	}
 
-	// extract
-	if err := decompressSource(pkg, extractRoot); err != nil {
+	// record uninstall
+	if err := removeFromManifest(PackageInfo{Name: pkgName}); err != nil {
 		return err
 	}
 
-	buildDir, err := postExtractDir(extractRoot)
+	eyes.Successf("Package %s uninstalled.", pkgName)
+	return nil
+}
+
+/****************************************************/
+// localRecipe returns the recipe of an installed package without going
+// to the repositories: the one recorded in the package database at
+// install time, or for packages installed before that, the cached one
+/****************************************************/
+func localRecipe(path string, pkgName string) (PackageInfo, bool) {
+	pkg, err := loadRecipeDB(pkgName)
+	if err == nil {
+		return pkg, true
+	}
+	if err != os.ErrNotExist {
+		eyes.Warnf("%v", err)
+	}
+	return cachedRecipe(path, pkgName)
+}
+
+/****************************************************/
+// cachedRecipe reads a recipe from the local recipes cache only,
+// unlike fetchpkg it never goes to the repositories (no network)
+/****************************************************/
+func cachedRecipe(path string, pkgName string) (PackageInfo, bool) {
+	data, err := os.ReadFile(filepath.Join(path, "recipes", pkgName+".json"))
 	if err != nil {
-		return err
+		return PackageInfo{}, false
 	}
 
-	if err := os.Chdir(buildDir); err != nil {
-		return err
+	var pkg PackageInfo
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		eyes.Warnf("Cached recipe of %s is invalid, ignoring it: %v", pkgName, err)
+		return PackageInfo{}, false
 	}
 
-	// env
-	for k, v := range pkg.Build.Env {
-		eyes.Infof("Setting environment variables.")
-		os.Setenv(k, v)
+	return pkg, true
+}
+
+/****************************************************/
+// repoRecipe reads a recipe straight from the synced repositories,
+// never the cache and never syncing, so it's what the repositories
+// offer right now
+/****************************************************/
+func repoRecipe(pkgName string) (PackageInfo, error) {
+	repo, name, err := resolveRecipe(pkgName)
+	if err != nil {
+		return PackageInfo{}, err
 	}
 
-	// install
-	for _, cmd := range pkg.Build.Uninstall {
-		eyes.Infof("Uninstalling package.")
-		if err := runCmd("sh", "-c", cmd); err != nil {
-			return err
-		}
+	data, err := readRecipe(repo, name)
+	if err != nil {
+		return PackageInfo{}, fmt.Errorf("failed to read package %s from repository %s: %v", name, repo.Name, err)
 	}
 
-	// record install
-	if err := removeFromManifest(pkg); err != nil {
-		return err
+	var pkg PackageInfo
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return PackageInfo{}, fmt.Errorf("recipe of %s in repository %s is invalid: %v", name, repo.Name, err)
 	}
 
-	return nil
+	return pkg, nil
 }
 
 /****************************************************/
 // updateAll updates all installed packages that have
-// a newer Release in the repo, using the manifest's release as
-// reference, it takes that and it checks if the Manifest's Release
-// is smaller than the repo release, if so, install the package again
+// a newer epoch:version-release in the repo, using the manifest's
+// entry as reference, it compares both with compareEVR and if the
+// repo one is newer, install the package again
 /****************************************************/
 func updateAll(path string) error {
 
@@ -541,10 +628,11 @@ func updateAll(path string) error {
 		return fmt.Errorf("failed to sync repositories: %v", err)
 	}
 
-	m, err := loadManifest()
+	db, err := packageDB()
 	if err != nil {
 		return err
 	}
+	m := db.Manifest
 
 	if len(m.Installed) == 0 {
 		eyes.Infof("No installed packages found.")
@@ -555,18 +643,25 @@ func updateAll(path string) error {
 
 	// check for updates
 	for _, inst := range m.Installed {
-		pkg, err := fetchpkg(path, false, inst.Name, true)
+		// compare against the repositories just synced, not the
+		// recipe cached when it was installed
+		pkg, err := repoRecipe(inst.Name)
 		if err != nil {
 			eyes.Warnf("Failed to fetch %s, skipping: %v", inst.Name, err)
 			continue
 		}
 
-		if int64(pkg.Release) > inst.Release {
+		if compareEVR(pkg.evr(), inst.evr()) > 0 {
+			// a pin still lets it move to exactly the pinned version
+			if hold := findHold(m, inst.Name); hold != nil && !hold.pins(pkg.evr()) {
+				eyes.Warnf("Held: %s (%s), skipping update %s → %s", inst.Name, hold, inst.evr(), pkg.evr())
+				continue
+			}
 			eyes.Infof(
-				"Update available: %s (%d → %d)",
+				"Update available: %s (%s → %s)",
 				inst.Name,
-				inst.Release,
-				pkg.Release,
+				inst.evr(),
+				pkg.evr(),
 			)
 			toUpdate = append(toUpdate, inst)
 		} else {
@@ -597,7 +692,7 @@ func updateAll(path string) error {
 	// perform updates
 	for _, p := range toUpdate {
 		eyes.Infof("Updating %s", p.Name)
-		if err := install(p.Name, true, path); err != nil {
+		if err := upgrade(p.Name, path); err != nil {
 			return fmt.Errorf("failed to update %s: %v", p.Name, err)
 		}
 	}
@@ -0,0 +1,148 @@
+/*
+  Blink, a powerful source-based package manager. Core of ApertureOS.
+	Want to use it for your own project?
+	Blink is completely FOSS (Free and Open Source),
+	edit, publish, use, contribute to Blink however you prefer.
+  Copyright (C) 2025-2026 Aperture OS
+
+  This program is free software: you can redistribute it and/or modify
+  it under the terms of the Apache 2.0 License as published by
+  the Apache Software Foundation, either version 2.0 of the License, or
+  any later version.
+
+  This program is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <https://www.apache.org/licenses/LICENSE-2.0>.
+*/
+
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+/****************************************************/
+// PackageDB is the manifest loaded in memory and indexed by name.
+// inside a transaction there's exactly one, loaded on first use and
+// flushed to disk (fsync'd, tmp + rename) when the transaction commits,
+// so resolving a big dependency graph doesn't decode the manifest again
+// for every node. outside of a transaction every Save writes through
+//
+// the embedded Manifest can be read directly, Installed must only be
+// changed through Put/Remove so the index stays right
+/****************************************************/
+type PackageDB struct {
+	Manifest
+	index map[string]int // name -> position in Installed
+	dirty bool
+}
+
+/****************************************************/
+// packageDB returns the transaction's database, loading it the first
+// time, or a freshly loaded one when no transaction is running
+/****************************************************/
+func packageDB() (*PackageDB, error) {
+	if currentTx != nil && currentTx.db != nil {
+		return currentTx.db, nil
+	}
+
+	m, err := loadManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	db := &PackageDB{Manifest: m}
+	db.reindex()
+
+	if currentTx != nil {
+		currentTx.db = db
+	}
+	return db, nil
+}
+
+// reindex rebuilds the name index after Installed changed shape
+func (db *PackageDB) reindex() {
+	db.index = make(map[string]int, len(db.Installed))
+	for i, p := range db.Installed {
+		db.index[p.Name] = i
+	}
+}
+
+// Get returns the entry of an installed package, the pointer can be
+// used to change it (then Save) until the next Put or Remove
+func (db *PackageDB) Get(name string) (*InstalledPkg, bool) {
+	i, ok := db.index[name]
+	if !ok {
+		return nil, false
+	}
+	return &db.Installed[i], true
+}
+
+// Has tells whether a package is installed
+func (db *PackageDB) Has(name string) bool {
+	_, ok := db.index[name]
+	return ok
+}
+
+// Put adds an entry, or replaces the one with the same name
+func (db *PackageDB) Put(p InstalledPkg) {
+	if i, ok := db.index[p.Name]; ok {
+		db.Installed[i] = p
+	} else {
+		db.Installed = append(db.Installed, p)
+		db.index[p.Name] = len(db.Installed) - 1
+	}
+	db.dirty = true
+}
+
+// Remove drops an entry, false if there was none
+func (db *PackageDB) Remove(name string) bool {
+	i, ok := db.index[name]
+	if !ok {
+		return false
+	}
+	db.Installed = append(db.Installed[:i], db.Installed[i+1:]...)
+	db.reindex()
+	db.dirty = true
+	return true
+}
+
+// Names returns the installed package names, sorted
+func (db *PackageDB) Names() []string {
+	names := make([]string, 0, len(db.Installed))
+	for _, p := range db.Installed {
+		names = append(names, p.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+/****************************************************/
+// Save records that db changed. the transaction's database is only
+// written when the transaction commits, any other one right away
+/****************************************************/
+func (db *PackageDB) Save() error {
+	db.dirty = true
+	if currentTx != nil && currentTx.db == db {
+		return nil
+	}
+	return db.Flush()
+}
+
+/****************************************************/
+// Flush writes db to disk if it changed since it was loaded
+/****************************************************/
+func (db *PackageDB) Flush() error {
+	if !db.dirty {
+		return nil
+	}
+	if err := saveManifest(db.Manifest); err != nil {
+		return fmt.Errorf("failed to write package database: %v", err)
+	}
+	db.dirty = false
+	return nil
+}
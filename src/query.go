@@ -0,0 +1,172 @@
+/*
+  Blink, a powerful source-based package manager. Core of ApertureOS.
+	Want to use it for your own project?
+	Blink is completely FOSS (Free and Open Source),
+	edit, publish, use, contribute to Blink however you prefer.
+  Copyright (C) 2025-2026 Aperture OS
+
+  This program is free software: you can redistribute it and/or modify
+  it under the terms of the Apache 2.0 License as published by
+  the Apache Software Foundation, either version 2.0 of the License, or
+  any later version.
+
+  This program is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <https://www.apache.org/licenses/LICENSE-2.0>.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+/****************************************************/
+// Query commands on top of the manifest + file database
+// owns:  which package put this file on disk?
+// files: which files does this package own?
+// both take globs ("*" matches "/" too, see globMatch) and can print JSON
+// for scripts. they only read, so no root and no lock needed
+/****************************************************/
+
+// ownsResult is one line of "blink owns" output
+type ownsResult struct {
+	Path   string   `json:"path"`
+	Owners []string `json:"owners"`
+	Owned  bool     `json:"owned"`
+}
+
+// filesResult is one package of "blink files" output
+type filesResult struct {
+	Package string      `json:"package"`
+	Version string      `json:"version"`
+	Tracked bool        `json:"tracked"` // false for packages installed before file tracking
+	Files   []FileEntry `json:"files"`
+}
+
+// isGlob reports whether s has any glob metacharacters
+func isGlob(s string) bool {
+	return strings.ContainsAny(s, "*?[")
+}
+
+/****************************************************/
+// queryOwns resolves every argument to the packages owning it
+// plain paths are made absolute (relative to the current directory),
+// globs are matched against every tracked path. with --root, paths
+// are inside the root ("/usr/bin/foo", not "/mnt/image/usr/bin/foo")
+/****************************************************/
+func queryOwns(args []string) ([]ownsResult, error) {
+	index, err := buildOwnerIndex("")
+	if err != nil {
+		return nil, err
+	}
+
+	var results []ownsResult
+
+	for _, arg := range args {
+		if isGlob(arg) {
+			var matched []string
+			for p := range index {
+				if globMatch(arg, p) {
+					matched = append(matched, p)
+				}
+			}
+			sort.Strings(matched)
+
+			if len(matched) == 0 {
+				results = append(results, ownsResult{Path: arg, Owners: []string{}})
+			}
+			for _, p := range matched {
+				results = append(results, ownsResult{Path: p, Owners: index[p], Owned: true})
+			}
+			continue
+		}
+
+		abs, err := filepath.Abs(arg)
+		if err != nil {
+			return nil, err
+		}
+
+		owners := index[abs]
+
+		// /bin/foo might be tracked as /usr/bin/foo when /bin is a symlink
+		// (only on the live system, symlinks inside --root point to the host)
+		if len(owners) == 0 && installRoot == "/" {
+			if real, err := filepath.EvalSymlinks(abs); err == nil && real != abs {
+				owners = index[real]
+			}
+		}
+
+		if owners == nil {
+			owners = []string{}
+		}
+		results = append(results, ownsResult{Path: abs, Owners: owners, Owned: len(owners) > 0})
+	}
+
+	return results, nil
+}
+
+/****************************************************/
+// queryFiles lists the recorded files of every installed package
+// whose name matches one of the arguments (exact name or glob)
+/****************************************************/
+func queryFiles(args []string) ([]filesResult, error) {
+	pdb, err := packageDB()
+	if err != nil {
+		return nil, err
+	}
+	m := pdb.Manifest
+
+	var results []filesResult
+
+	for _, arg := range args {
+		found := false
+
+		for _, inst := range m.Installed {
+			if inst.Name != arg && !(isGlob(arg) && globMatch(arg, inst.Name)) {
+				continue
+			}
+			found = true
+
+			db, err := loadFileDB(inst.Name)
+			if err != nil && err != os.ErrNotExist {
+				return nil, err
+			}
+
+			files := db.Files
+			if files == nil {
+				files = []FileEntry{}
+			}
+
+			results = append(results, filesResult{
+				Package: inst.Name,
+				Version: inst.evr().String(),
+				Tracked: err == nil,
+				Files:   files,
+			})
+		}
+
+		if !found {
+			return nil, fmt.Errorf("package %s is not installed", arg)
+		}
+	}
+
+	return results, nil
+}
+
+/****************************************************/
+// printJSON is the shared --json output of the query commands
+/****************************************************/
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
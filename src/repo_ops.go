@@ -0,0 +1,352 @@
+/*
+  Blink, a powerful source-based package manager. Core of ApertureOS.
+	Want to use it for your own project?
+	Blink is completely FOSS (Free and Open Source),
+	edit, publish, use, contribute to Blink however you prefer.
+  Copyright (C) 2025-2026 Aperture OS
+
+  This program is free software: you can redistribute it and/or modify
+  it under the terms of the Apache 2.0 License as published by
+  the Apache Software Foundation, either version 2.0 of the License, or
+  any later version.
+
+  This program is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <https://www.apache.org/licenses/LICENSE-2.0>.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Aperture-OS/eyes"
+)
+
+/****************************************************/
+// Repository management: blink repo add/remove/list/enable/disable
+// edit config.toml through LoadRepos/SaveRepos, and keep the clones
+// under repoCachePath in step with it
+//
+// every sync also writes repoCachePath/<name>.sync.json with the revision
+// the repository ended up at, so "blink repo list" can tell how stale it is
+// (and <name>.index.json, see index.go)
+/****************************************************/
+
+// repoSyncState is repoCachePath/<name>.sync.json
+type repoSyncState struct {
+	Revision string    `json:"revision"` // commit, or sha256 of INDEX for http repositories
+	Synced   time.Time `json:"synced"`
+	Frozen   bool      `json:"frozen,omitempty"` // synced from a lock file, left alone until the next blink sync
+}
+
+// shortRevision shortens a commit or "sha256:..." revision for display
+func shortRevision(rev string) string {
+	prefix := ""
+	if strings.HasPrefix(rev, "sha256:") {
+		prefix, rev = "sha256:", strings.TrimPrefix(rev, "sha256:")
+	}
+	if len(rev) > 12 {
+		rev = rev[:12]
+	}
+	return prefix + rev
+}
+
+// repo names end up as directory names and in "repo/pkg" specs
+var repoNameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+// syncStatePath is where the sync state of a repository lives, next
+// to its clone rather than inside it
+func syncStatePath(name string) string {
+	return filepath.Join(repoCachePath, name+".sync.json")
+}
+
+/****************************************************/
+// recordSync stores the revision a synced repository is at, called
+// after every successful sync
+/****************************************************/
+func recordSync(repo RepoConfig, frozen bool) error {
+	name := repo.Name
+	rev, err := repoRevision(repo)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(repoSyncState{
+		Revision: rev,
+		Frozen:   frozen,
+		Synced:   time.Now(),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := syncStatePath(name) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, syncStatePath(name))
+}
+
+// loadSyncState returns the last recorded sync, false if the
+// repository was never synced
+func loadSyncState(name string) (repoSyncState, bool) {
+	var state repoSyncState
+
+	data, err := os.ReadFile(syncStatePath(name))
+	if err != nil {
+		return state, false
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, false
+	}
+	return state, true
+}
+
+/****************************************************/
+// addRepo validates a new repository by syncing it, and only adds it
+// to the config once that worked
+/****************************************************/
+func addRepo(repo RepoConfig) error {
+	name := repo.Name
+	if !repoNameRe.MatchString(name) {
+		return fmt.Errorf("invalid repository name %q (letters, digits, '-' and '_' only)", name)
+	}
+	switch repo.Type {
+	case repoTypeGit:
+		if repo.Ref == "" {
+			repo.Ref = "main"
+		}
+	case repoTypeHTTP:
+		repo.Ref = ""
+	case repoTypeLocal:
+		repo.Ref = ""
+		abs, err := filepath.Abs(repo.URL)
+		if err != nil {
+			return err
+		}
+		repo.URL = abs
+	default:
+		return fmt.Errorf("unknown repository type %q (%s, %s or %s)", repo.Type, repoTypeGit, repoTypeHTTP, repoTypeLocal)
+	}
+
+	repos, err := LoadConfig()
+	if err != nil {
+		return err
+	}
+	if _, ok := FindRepoByName(name, repos); ok {
+		return fmt.Errorf("repository %s already exists", name)
+	}
+
+	if err := os.MkdirAll(repoCachePath, 0755); err != nil {
+		return err
+	}
+
+	// a leftover copy of a repository that was dropped from the
+	// config by hand would make git clone fail
+	if err := removeRepoFiles(name); err != nil {
+		return err
+	}
+
+	dest := repoDir(repo)
+	if repo.Type != repoTypeLocal {
+		eyes.Infof("Fetching %s into %s...", repo.URL, dest)
+	}
+	signed, err := syncRepo(repo, true, "") // from repository.go
+	if err != nil {
+		removeRepoFiles(name)
+		return fmt.Errorf("%v, repository not added", err)
+	}
+	if !signed && repo.Type != repoTypeLocal {
+		eyes.Warnf("%s is not signed, its recipes can't be checked for authenticity.", name)
+	}
+
+	recipes, _ := filepath.Glob(filepath.Join(dest, "*.json"))
+	if len(recipes) == 0 {
+		eyes.Warnf("%s has no recipes (*.json) at its top level.", name)
+	}
+
+	repos[name] = repo
+	if err := SaveRepos(configPath, repos); err != nil {
+		removeRepoFiles(name)
+		return err
+	}
+
+	eyes.Successf("Added repository %s with %d recipe(s).", name, len(recipes))
+	return nil
+}
+
+// removeRepoFiles deletes everything sync keeps for a repository, the
+// directory of a local repository is not ours and stays
+func removeRepoFiles(name string) error {
+	os.Remove(syncStatePath(name))
+	os.Remove(indexPath(name))
+	os.Remove(httpCachePath(name))
+	return os.RemoveAll(filepath.Join(repoCachePath, name))
+}
+
+/****************************************************/
+// removeRepo drops a repository from the config and deletes its copy,
+// installed packages from it stay installed
+/****************************************************/
+func removeRepo(name string) error {
+	repos, err := LoadConfig()
+	if err != nil {
+		return err
+	}
+	if _, ok := FindRepoByName(name, repos); !ok {
+		return fmt.Errorf("repository %s is not configured", name)
+	}
+	if len(repos) == 1 {
+		return fmt.Errorf("%s is the only repository, add another one first", name)
+	}
+
+	delete(repos, name)
+	if err := SaveRepos(configPath, repos); err != nil {
+		return err
+	}
+
+	if err := removeRepoFiles(name); err != nil {
+		return fmt.Errorf("removed %s from the config, but not its files: %v", name, err)
+	}
+
+	if db, err := packageDB(); err == nil {
+		var left []string
+		for _, p := range db.Installed {
+			if p.Repo == name {
+				left = append(left, p.Name)
+			}
+		}
+		if len(left) > 0 {
+			eyes.Warnf("Still installed from %s: %s", name, strings.Join(left, ", "))
+		}
+	}
+
+	eyes.Successf("Removed repository %s.", name)
+	return nil
+}
+
+/****************************************************/
+// setRepoEnabled flips the disabled flag of a repository, a disabled
+// repository stays in the config and on disk but is neither synced
+// nor used to resolve packages
+/****************************************************/
+func setRepoEnabled(name string, enabled bool) error {
+	repos, err := LoadConfig()
+	if err != nil {
+		return err
+	}
+	repo, ok := FindRepoByName(name, repos)
+	if !ok {
+		return fmt.Errorf("repository %s is not configured", name)
+	}
+
+	if repo.Disabled == !enabled {
+		eyes.Infof("Repository %s is already %s.", name, repoStatus(repo))
+		return nil
+	}
+
+	repo.Disabled = !enabled
+	repos[name] = repo
+	if err := SaveRepos(configPath, repos); err != nil {
+		return err
+	}
+
+	eyes.Successf("Repository %s %s.", name, repoStatus(repo))
+	return nil
+}
+
+func repoStatus(repo RepoConfig) string {
+	if repo.Disabled {
+		return "disabled"
+	}
+	return "enabled"
+}
+
+// repoListing is one entry of "blink repo list --json"
+type repoListing struct {
+	Name         string     `json:"name"`
+	Type         string     `json:"type"`
+	URL          string     `json:"url"`
+	Branch       string     `json:"branch,omitempty"`
+	Priority     int        `json:"priority"`
+	Enabled      bool       `json:"enabled"`
+	Signed       bool       `json:"signed"`
+	LastRevision string     `json:"last_revision,omitempty"`
+	LastSync     *time.Time `json:"last_sync,omitempty"`
+	Frozen       bool       `json:"frozen,omitempty"`
+}
+
+/****************************************************/
+// listRepos prints the configured repositories in resolution order
+/****************************************************/
+func listRepos(asJSON bool) error {
+	repos, err := LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	var list []repoListing
+	for _, repo := range sortedRepos(repos) {
+		entry := repoListing{
+			Name:     repo.Name,
+			Type:     repo.Type,
+			URL:      repo.URL,
+			Branch:   repo.Ref,
+			Priority: repo.Priority,
+			Enabled:  !repo.Disabled,
+			Signed:   repo.Signed,
+		}
+		if state, ok := loadSyncState(repo.Name); ok {
+			entry.LastRevision = state.Revision
+			entry.LastSync = &state.Synced
+			entry.Frozen = state.Frozen
+		}
+		list = append(list, entry)
+	}
+
+	if asJSON {
+		return printJSON(list)
+	}
+
+	for _, r := range list {
+		synced := "never synced"
+		if r.LastSync != nil {
+			synced = fmt.Sprintf("%s at %s", shortRevision(r.LastRevision), r.LastSync.Format("2006-01-02 15:04"))
+			if r.Frozen {
+				synced += " (frozen by a lock file)"
+			}
+		}
+
+		status := "enabled"
+		if !r.Enabled {
+			status = "disabled"
+		}
+		if r.Signed {
+			status += ", signed"
+		}
+
+		fmt.Printf("%s (%s)\n", r.Name, status)
+		switch r.Type {
+		case repoTypeLocal:
+			synced = "read in place"
+			fmt.Printf("  Path:      %s (local overlay)\n", r.URL)
+		case repoTypeHTTP:
+			fmt.Printf("  URL:       %s (http, priority %d)\n", r.URL, r.Priority)
+		default:
+			fmt.Printf("  URL:       %s (branch %s, priority %d)\n", r.URL, r.Branch, r.Priority)
+		}
+		fmt.Printf("  Last sync: %s\n", synced)
+	}
+
+	return nil
+}
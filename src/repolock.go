@@ -0,0 +1,175 @@
+/*
+  Blink, a powerful source-based package manager. Core of ApertureOS.
+	Want to use it for your own project?
+	Blink is completely FOSS (Free and Open Source),
+	edit, publish, use, contribute to Blink however you prefer.
+  Copyright (C) 2025-2026 Aperture OS
+
+  This program is free software: you can redistribute it and/or modify
+  it under the terms of the Apache 2.0 License as published by
+  the Apache Software Foundation, either version 2.0 of the License, or
+  any later version.
+
+  This program is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <https://www.apache.org/licenses/LICENSE-2.0>.
+*/
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/Aperture-OS/eyes"
+)
+
+/****************************************************/
+// Repository lock file: "blink sync --lock" syncs as usual and writes
+// the revision every repository ended up at to repoLockPath, "blink
+// sync --frozen" brings every repository to exactly that revision.
+// ship the same lock file to a fleet and every machine gets the same
+// recipes, roll forward by syncing --lock once and shipping the new one
+//
+// frozen repositories stay put: the syncs install and friends do on
+// their own leave them alone until the next plain "blink sync"
+/****************************************************/
+
+// repoLock is the lock file
+type repoLock struct {
+	Generated time.Time             `toml:"generated"`
+	Repos     map[string]lockedRepo `toml:"repo"`
+}
+
+// lockedRepo is one repository of the lock file, type and url are
+// there to notice a lock that was written for a different config
+type lockedRepo struct {
+	Type     string `toml:"type"`
+	URL      string `toml:"url"`
+	Revision string `toml:"revision"` // commit, or sha256 of INDEX for http repositories
+}
+
+/****************************************************/
+// writeRepoLock records the revision of every enabled repository,
+// local overlays are read in place and can't be locked
+/****************************************************/
+func writeRepoLock(file string) error {
+	repos, err := LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	lock := repoLock{Generated: time.Now().UTC(), Repos: make(map[string]lockedRepo)}
+	for _, repo := range sortedRepos(repos) {
+		if repo.Disabled {
+			continue
+		}
+		if repo.Type == repoTypeLocal {
+			eyes.Warnf("Not locking %s, local repositories are read in place.", repo.Name)
+			continue
+		}
+
+		rev, err := repoRevision(repo)
+		if err != nil {
+			return fmt.Errorf("repository %s: %v", repo.Name, err)
+		}
+		lock.Repos[repo.Name] = lockedRepo{Type: repo.Type, URL: repo.URL, Revision: rev}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("# written by blink sync --lock, use with blink sync --frozen\n")
+	if err := toml.NewEncoder(&buf).Encode(lock); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		return err
+	}
+
+	eyes.Successf("Locked %d repositories in %s.", len(lock.Repos), file)
+	return nil
+}
+
+/****************************************************/
+// loadRepoLock reads a lock file
+/****************************************************/
+func loadRepoLock(file string) (repoLock, error) {
+	var lock repoLock
+	if _, err := toml.DecodeFile(file, &lock); err != nil {
+		if os.IsNotExist(err) {
+			return lock, fmt.Errorf("no lock file at %s, write one with 'blink sync --lock'", file)
+		}
+		return lock, fmt.Errorf("failed to decode %s: %v", file, err)
+	}
+	return lock, nil
+}
+
+/****************************************************/
+// syncFrozen brings every enabled repository to the revision in the
+// lock file. every repository has to be in the lock, and still point
+// where it pointed when the lock was written
+/****************************************************/
+func syncFrozen(file string) error {
+	lock, err := loadRepoLock(file)
+	if err != nil {
+		return err
+	}
+
+	repos, err := LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	// check the whole lock first, so a mismatch doesn't leave half the
+	// repositories moved
+	var todo []RepoConfig
+	for _, repo := range sortedRepos(repos) {
+		if repo.Disabled {
+			continue
+		}
+		if repo.Type == repoTypeLocal {
+			eyes.Warnf("Repository %s is local, it is read in place and not locked.", repo.Name)
+			continue
+		}
+
+		locked, ok := lock.Repos[repo.Name]
+		if !ok {
+			return fmt.Errorf("repository %s is not in %s, write a new lock with 'blink sync --lock'", repo.Name, file)
+		}
+		if locked.Type != repo.Type || locked.URL != repo.URL {
+			return fmt.Errorf("repository %s is %s %s, but was %s %s when %s was written", repo.Name, repo.Type, repo.URL, locked.Type, locked.URL, file)
+		}
+		todo = append(todo, repo)
+	}
+	for name := range lock.Repos {
+		if repo, ok := repos[name]; !ok || repo.Disabled {
+			eyes.Warnf("Repository %s is in the lock file but not enabled, skipping it.", name)
+		}
+	}
+
+	for _, repo := range todo {
+		want := lock.Repos[repo.Name].Revision
+		eyes.Infof("Bringing %s to %s...", repo.Name, shortRevision(want))
+		if _, err := syncRepo(repo, false, want); err != nil {
+			return err
+		}
+	}
+
+	eyes.Successf("Repositories frozen at %s (locked %s).", file, lock.Generated.Format("2006-01-02 15:04"))
+	return nil
+}
@@ -21,14 +21,46 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
 
 	"github.com/BurntSushi/toml"
+
+	"github.com/Aperture-OS/eyes"
+)
+
+// repository types, see RepoConfig.Type
+const (
+	repoTypeGit   = "git"   // cloned and pulled with git
+	repoTypeHTTP  = "http"  // static files, see http_repo.go
+	repoTypeLocal = "local" // a directory read in place, layered above the rest
 )
 
+// recipeNameRe is what the package half of a spec may look like, it
+// becomes <repo>/<name>.json so no separators and no "..", like repoNameRe
+var recipeNameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9+._-]*$`)
+
+// repoFile is how a repository looks in config.toml
+type repoFile struct {
+	Type     string `toml:"type,omitempty"` // "git" (default), "http" or "local"
+	GitURL   string `toml:"git_url,omitempty"`
+	URL      string `toml:"url,omitempty"`  // base URL of http repositories
+	Path     string `toml:"path,omitempty"` // directory of local repositories
+	Branch   string `toml:"branch,omitempty"`
+	Priority int    `toml:"priority,omitzero"`
+	Disabled bool   `toml:"disabled,omitempty"`
+	Signed   bool   `toml:"signed,omitempty"`
+}
+
 /****************************************************/
 // LoadRepos reads a TOML file and returns a map of repository name -> RepoConfig
 /****************************************************/
@@ -37,10 +69,7 @@ func LoadRepos(path string) (map[string]RepoConfig, error) {
 		return nil, fmt.Errorf("config file does not exist: %s", path)
 	}
 
-	var raw map[string]struct {
-		GitURL string `toml:"git_url"`
-		Branch string `toml:"branch"`
-	}
+	var raw map[string]repoFile
 
 	if _, err := toml.DecodeFile(path, &raw); err != nil {
 		return nil, fmt.Errorf("failed to decode TOML: %v", err)
@@ -48,10 +77,29 @@ func LoadRepos(path string) (map[string]RepoConfig, error) {
 
 	result := make(map[string]RepoConfig)
 	for name, r := range raw {
+		url := r.GitURL
+		switch r.Type {
+		case "", repoTypeGit:
+			r.Type = repoTypeGit
+		case repoTypeHTTP:
+			url = r.URL
+		case repoTypeLocal:
+			if !filepath.IsAbs(r.Path) {
+				return nil, fmt.Errorf("local repository %s needs an absolute path, got %q", name, r.Path)
+			}
+			url = r.Path
+		default:
+			return nil, fmt.Errorf("repository %s has unknown type %q", name, r.Type)
+		}
+
 		result[name] = RepoConfig{
-			Name: name,
-			URL:  r.GitURL,
-			Ref:  r.Branch,
+			Name:     name,
+			Type:     r.Type,
+			URL:      url,
+			Ref:      r.Branch,
+			Priority: r.Priority,
+			Disabled: r.Disabled,
+			Signed:   r.Signed,
 		}
 	}
 
@@ -62,19 +110,35 @@ func LoadRepos(path string) (map[string]RepoConfig, error) {
 // SaveRepos writes a map of RepoConfig to a TOML file
 /****************************************************/
 func SaveRepos(path string, repos map[string]RepoConfig) error {
-	raw := make(map[string]struct {
-		GitURL string `toml:"git_url"`
-		Branch string `toml:"branch"`
-	})
+	raw := make(map[string]repoFile)
 
 	for name, repo := range repos {
-		raw[name] = struct {
-			GitURL string `toml:"git_url"`
-			Branch string `toml:"branch"`
-		}{
-			GitURL: repo.URL,
-			Branch: repo.Ref,
+		r := repoFile{
+			GitURL:   repo.URL,
+			Branch:   repo.Ref,
+			Priority: repo.Priority,
+			Disabled: repo.Disabled,
+			Signed:   repo.Signed,
 		}
+		switch repo.Type {
+		case repoTypeHTTP:
+			r = repoFile{
+				Type:     repoTypeHTTP,
+				URL:      repo.URL,
+				Priority: repo.Priority,
+				Disabled: repo.Disabled,
+				Signed:   repo.Signed,
+			}
+		case repoTypeLocal:
+			r = repoFile{
+				Type:     repoTypeLocal,
+				Path:     repo.URL,
+				Priority: repo.Priority,
+				Disabled: repo.Disabled,
+				Signed:   repo.Signed,
+			}
+		}
+		raw[name] = r
 	}
 
 	file, err := os.Create(path)
@@ -89,6 +153,7 @@ func SaveRepos(path string, repos map[string]RepoConfig) error {
 
 	return nil
 }
+
 /****************************************************/
 // FindRepoByName searches for a repository by name in a map of RepoConfig
 /****************************************************/
@@ -98,34 +163,203 @@ func FindRepoByName(name string, repos map[string]RepoConfig) (RepoConfig, bool)
 }
 
 /****************************************************/
-// ensureRepo makes sure all configured repositories are present and up to date
+// sortedRepos returns the repositories in resolution order: local
+// overlays before everything else, then highest priority first, equal
+// priorities by name, so the same config always picks the same
+// repository for a package
+/****************************************************/
+func sortedRepos(repos map[string]RepoConfig) []RepoConfig {
+	list := make([]RepoConfig, 0, len(repos))
+	for _, repo := range repos {
+		list = append(list, repo)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		iLocal, jLocal := list[i].Type == repoTypeLocal, list[j].Type == repoTypeLocal
+		if iLocal != jLocal {
+			return iLocal
+		}
+		if list[i].Priority != list[j].Priority {
+			return list[i].Priority > list[j].Priority
+		}
+		return list[i].Name < list[j].Name
+	})
+
+	return list
+}
+
+/****************************************************/
+// splitRepoSpec splits "repo/pkg" into its repository and package,
+// the repository is empty for a plain "pkg"
+/****************************************************/
+func splitRepoSpec(spec string) (string, string) {
+	if repo, pkg, found := strings.Cut(spec, "/"); found {
+		return repo, pkg
+	}
+	return "", spec
+}
+
+// repoDir is where the recipes of a repository are: its synced copy
+// under repoCachePath, or the directory itself for local repositories
+func repoDir(repo RepoConfig) string {
+	if repo.Type == repoTypeLocal {
+		return repo.URL
+	}
+	return filepath.Join(repoCachePath, repo.Name)
+}
+
+// recipeFile is where a repository keeps the recipe of a package
+func recipeFile(repo RepoConfig, pkgName string) string {
+	return filepath.Join(repoDir(repo), pkgName+".json")
+}
+
+// describeRepo says where a repository comes from, for info output
+func describeRepo(repo RepoConfig) string {
+	switch repo.Type {
+	case repoTypeLocal:
+		return "local overlay at " + repo.URL
+	case repoTypeHTTP:
+		return repo.URL + ", http"
+	}
+	return repo.URL
+}
+
+/****************************************************/
+// repoProviders returns every enabled repository that has a recipe
+// for pkgName, in resolution order. the first one is the one that wins
+/****************************************************/
+func repoProviders(pkgName string) ([]RepoConfig, error) {
+	if !recipeNameRe.MatchString(pkgName) {
+		return nil, fmt.Errorf("invalid package name %q", pkgName)
+	}
+
+	repos, err := LoadRepos(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var providers []RepoConfig
+	for _, repo := range sortedRepos(repos) {
+		if repo.Disabled {
+			continue
+		}
+		if _, err := os.Stat(recipeFile(repo, pkgName)); err == nil {
+			providers = append(providers, repo)
+		}
+	}
+
+	return providers, nil
+}
+
+/****************************************************/
+// resolveRecipe finds the repository a package spec resolves to.
+// "repo/pkg" must come from that repository, "pkg" comes from the
+// highest priority repository that has it. returns the repository
+// and the bare package name
+/****************************************************/
+func resolveRecipe(spec string) (RepoConfig, string, error) {
+	repoName, pkgName := splitRepoSpec(spec)
+	if !recipeNameRe.MatchString(pkgName) {
+		return RepoConfig{}, "", fmt.Errorf("invalid package %q, expected <pkg> or <repo>/<pkg>", spec)
+	}
+
+	if repoName != "" {
+		repos, err := LoadRepos(configPath)
+		if err != nil {
+			return RepoConfig{}, "", err
+		}
+
+		repo, ok := FindRepoByName(repoName, repos)
+		if !ok {
+			return RepoConfig{}, "", fmt.Errorf("repository %s is not configured", repoName)
+		}
+		if repo.Disabled {
+			return RepoConfig{}, "", fmt.Errorf("repository %s is disabled, run 'blink repo enable %s' first", repoName, repoName)
+		}
+		if _, err := os.Stat(recipeFile(repo, pkgName)); err != nil {
+			return RepoConfig{}, "", fmt.Errorf("package %s not found in repository %s", pkgName, repoName)
+		}
+		return repo, pkgName, nil
+	}
+
+	providers, err := repoProviders(pkgName)
+	if err != nil {
+		return RepoConfig{}, "", err
+	}
+	if len(providers) == 0 {
+		return RepoConfig{}, "", fmt.Errorf("package %s not found in any repository", pkgName)
+	}
+
+	return providers[0], pkgName, nil
+}
+
+/****************************************************/
+// recipeOrigin finds which synced repository a recipe came from and
+// the commit that repository is at, for the manifest. that's the first
+// repository (in resolution order) with the exact same recipe, so an
+// explicit "repo/pkg" is recorded right too. empty strings when it
+// can't be told (recipe copied in by hand...)
+/****************************************************/
+func recipeOrigin(pkg PackageInfo) (string, string) {
+	providers, err := repoProviders(pkg.Name)
+	if err != nil {
+		return "", ""
+	}
+
+	for _, repo := range providers {
+		data, err := os.ReadFile(recipeFile(repo, pkg.Name))
+		if err != nil {
+			continue
+		}
+
+		var candidate PackageInfo
+		if err := json.Unmarshal(data, &candidate); err != nil || !reflect.DeepEqual(candidate, pkg) {
+			continue
+		}
+
+		rev, err := repoRevision(repo)
+		if err != nil {
+			return repo.Name, ""
+		}
+		return repo.Name, rev
+	}
+
+	return "", ""
+}
+
+/****************************************************/
+// ensureRepo makes sure all enabled repositories are present and up to
+// date before a command uses them. repositories frozen by
+// "blink sync --frozen" stay where they are
 /****************************************************/
 func ensureRepo(force bool) error {
+	return syncAll(force, false)
+}
+
+/****************************************************/
+// syncAll syncs every enabled repository in resolution order, see
+// syncRepo. thaw moves frozen repositories to their tip too, that's
+// what an explicit "blink sync" does
+/****************************************************/
+func syncAll(force, thaw bool) error {
 	repos, err := LoadConfig() // from config.go
 	if err != nil {
 		return err
 	}
 
-	for name, repo := range repos {
-		repoPath := filepath.Join(repoCachePath, name)
-
-		if _, err := os.Stat(repoPath); os.IsNotExist(err) {
-			// clone
-			if err := cloneRepo(repo.URL, repo.Ref, repoPath); err != nil {
-				return err
-			}
+	for _, repo := range sortedRepos(repos) {
+		if repo.Disabled {
 			continue
 		}
 
-		if force {
-			if err := resetRepo(repoPath, repo.Ref); err != nil {
-				return err
+		if state, ok := loadSyncState(repo.Name); ok && state.Frozen && !thaw {
+			if _, err := os.Stat(repoDir(repo)); err == nil {
+				eyes.Infof("Repository %s is frozen at %s, 'blink sync' moves it on.", repo.Name, shortRevision(state.Revision))
+				continue
 			}
-			continue
 		}
 
-		// pull
-		if err := pullRepo(repoPath); err != nil {
+		if _, err := syncRepo(repo, force, ""); err != nil {
 			return err
 		}
 	}
@@ -133,6 +367,143 @@ func ensureRepo(force bool) error {
 	return nil
 }
 
+/****************************************************/
+// syncRepo brings one repository up to date, or to revision want when
+// it isn't empty (see repolock.go), and records the revision it is at
+// along with a fresh package index. a repository whose signature
+// doesn't verify is put back where it was and the sync fails. reports
+// whether the repository is signed
+/****************************************************/
+func syncRepo(repo RepoConfig, force bool, want string) (bool, error) {
+	var index RepoIndex
+	var signed bool
+	var err error
+
+	switch repo.Type {
+	case repoTypeLocal:
+		return syncLocalRepo(repo)
+	case repoTypeHTTP:
+		index, signed, err = syncHTTPRepo(repo, force, want) // from http_repo.go
+	default:
+		index, signed, err = syncGitRepo(repo, force, want)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if err := recordSync(repo, want != ""); err != nil {
+		eyes.Warnf("Could not record sync state of %s: %v", repo.Name, err)
+	}
+
+	if signed {
+		err = saveRepoIndex(repo.Name, index)
+	} else {
+		err = writeRepoIndex(repo)
+	}
+	if err != nil {
+		eyes.Warnf("Could not index %s: %v", repo.Name, err)
+	}
+
+	return signed, nil
+}
+
+/****************************************************/
+// syncLocalRepo only checks a local repository, it is read in place so
+// there is nothing to fetch, and no index or sync state to keep
+/****************************************************/
+func syncLocalRepo(repo RepoConfig) (bool, error) {
+	info, err := os.Stat(repo.URL)
+	if err != nil {
+		return false, fmt.Errorf("local repository %s: %v", repo.Name, err)
+	}
+	if !info.IsDir() {
+		return false, fmt.Errorf("local repository %s: %s is not a directory", repo.Name, repo.URL)
+	}
+
+	_, signed, err := verifyRepo(repo, repo.URL) // from signing.go
+	if err != nil {
+		return false, fmt.Errorf("refusing repository %s: %v", repo.Name, err)
+	}
+
+	return signed, nil
+}
+
+/****************************************************/
+// syncGitRepo clones or pulls a git repository, or checks out commit
+// want, and verifies it
+/****************************************************/
+func syncGitRepo(repo RepoConfig, force bool, want string) (RepoIndex, bool, error) {
+	repoPath := repoDir(repo)
+
+	prev := "" // commit before syncing, empty for a fresh clone
+	if _, err := os.Stat(repoPath); os.IsNotExist(err) {
+		// clone
+		if err := cloneRepo(repo.URL, repo.Ref, repoPath); err != nil {
+			os.RemoveAll(repoPath)
+			return RepoIndex{}, false, err
+		}
+		if want != "" {
+			if err := checkoutLocked(repoPath, want); err != nil {
+				os.RemoveAll(repoPath)
+				return RepoIndex{}, false, fmt.Errorf("repository %s: %v", repo.Name, err)
+			}
+		}
+	} else {
+		if prev, err = repoHead(repoPath); err != nil {
+			return RepoIndex{}, false, err
+		}
+		switch {
+		case want != "":
+			err = checkoutLocked(repoPath, want)
+			if err != nil {
+				err = fmt.Errorf("repository %s: %v", repo.Name, err)
+			}
+		case force:
+			err = resetRepo(repoPath, repo.Ref)
+		default:
+			err = pullRepo(repoPath) // pull
+		}
+		if err != nil {
+			return RepoIndex{}, false, err
+		}
+	}
+
+	index, signed, err := verifyRepo(repo, repoPath) // from signing.go
+	if err != nil {
+		if prev == "" {
+			os.RemoveAll(repoPath)
+		} else if err := checkoutCommit(repoPath, prev); err != nil {
+			eyes.Errorf("Could not put %s back to %s: %v", repo.Name, prev, err)
+		}
+		return RepoIndex{}, false, fmt.Errorf("refusing repository %s: %v", repo.Name, err)
+	}
+
+	return index, signed, nil
+}
+
+/****************************************************/
+// repoRevision is what a synced repository is at: the commit for git
+// repositories, the sha256 of INDEX for http ones. local repositories
+// change under our feet, they have none
+/****************************************************/
+func repoRevision(repo RepoConfig) (string, error) {
+	repoPath := repoDir(repo)
+
+	if repo.Type == repoTypeLocal {
+		return "", nil
+	}
+	if repo.Type == repoTypeHTTP {
+		data, err := os.ReadFile(filepath.Join(repoPath, repoIndexFile))
+		if err != nil {
+			return "", err
+		}
+		sum := sha256.Sum256(data)
+		return "sha256:" + hex.EncodeToString(sum[:]), nil
+	}
+
+	return repoHead(repoPath)
+}
+
 /****************************************************/
 // some commands boilerplate functions to improve KISS 
 // and readability
@@ -144,6 +515,38 @@ func cloneRepo(url, ref, dest string) error {
 	return cmd.Run()
 }
 
+func repoHead(path string) (string, error) {
+	out, err := exec.Command("git", "-C", path, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to read HEAD of %s: %v", path, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// checkoutLocked checks out commit, fetching first when the clone
+// doesn't have it yet
+func checkoutLocked(path, commit string) error {
+	if exec.Command("git", "-C", path, "cat-file", "-e", commit+"^{commit}").Run() != nil {
+		cmd := exec.Command("git", "-C", path, "fetch", "--all")
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return err
+		}
+		if exec.Command("git", "-C", path, "cat-file", "-e", commit+"^{commit}").Run() != nil {
+			return fmt.Errorf("locked commit %s is not in the repository", commit)
+		}
+	}
+	return checkoutCommit(path, commit)
+}
+
+func checkoutCommit(path, commit string) error {
+	cmd := exec.Command("git", "-C", path, "reset", "--hard", "-q", commit)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
 func pullRepo(path string) error {
 	cmd := exec.Command("git", "-C", path, "pull")
 	cmd.Stdout = os.Stdout
@@ -0,0 +1,311 @@
+/*
+  Blink, a powerful source-based package manager. Core of ApertureOS.
+	Want to use it for your own project?
+	Blink is completely FOSS (Free and Open Source),
+	edit, publish, use, contribute to Blink however you prefer.
+  Copyright (C) 2025-2026 Aperture OS
+
+  This program is free software: you can redistribute it and/or modify
+  it under the terms of the Apache 2.0 License as published by
+  the Apache Software Foundation, either version 2.0 of the License, or
+  any later version.
+
+  This program is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <https://www.apache.org/licenses/LICENSE-2.0>.
+*/
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Aperture-OS/eyes"
+)
+
+/****************************************************/
+// blink search <query> looks through the package index of every
+// enabled repository. matches are ranked in tiers:
+//
+//   0 exact name        3 description contains the query
+//   1 name prefix       4 fuzzy name (query letters in order)
+//   2 name contains     5 fuzzy description word
+//
+// inside a tier shorter names / tighter fuzzy matches come first, then
+// repositories in resolution order. search only reads, so no root
+/****************************************************/
+
+const (
+	searchSubstring = "substring"
+	searchRegex     = "regex"
+	searchFuzzy     = "fuzzy"
+)
+
+// searchResult is one package of one repository that matched
+type searchResult struct {
+	Repo        string `json:"repo"`
+	Name        string `json:"name"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+	License     string `json:"license,omitempty"`
+	Installed   string `json:"installed,omitempty"`   // installed version, empty if not installed
+	Overlay     bool   `json:"overlay,omitempty"`     // from a local overlay repository
+	Shadowed    bool   `json:"shadowed,omitempty"`    // a repository earlier in resolution order has it too
+	ShadowedBy  string `json:"shadowed_by,omitempty"` // that repository
+
+	tier  int
+	score int
+	order int // position of the repository in resolution order
+}
+
+/****************************************************/
+// matchEntry decides whether an index entry matches the query and how
+// well, query is lowercased already (re is used for regex mode)
+/****************************************************/
+func matchEntry(e IndexEntry, query, mode string, re *regexp.Regexp) (int, int, bool) {
+	name := strings.ToLower(e.Name)
+	desc := strings.ToLower(e.Description)
+
+	if mode == searchRegex {
+		switch {
+		case re.FindString(e.Name) == e.Name:
+			return 0, len(name), true
+		case re.MatchString(e.Name):
+			return 2, len(name), true
+		case re.MatchString(e.Description):
+			return 3, len(name), true
+		}
+		return 0, 0, false
+	}
+
+	switch {
+	case name == query:
+		return 0, 0, true
+	case strings.HasPrefix(name, query):
+		return 1, len(name), true
+	case strings.Contains(name, query):
+		return 2, len(name), true
+	case strings.Contains(desc, query):
+		return 3, len(name), true
+	}
+
+	if mode != searchFuzzy {
+		return 0, 0, false
+	}
+
+	if gaps, ok := fuzzyGaps(name, query); ok {
+		return 4, gaps, true
+	}
+	best := -1
+	for _, word := range strings.Fields(desc) {
+		if gaps, ok := fuzzyGaps(word, query); ok && (best < 0 || gaps < best) {
+			best = gaps
+		}
+	}
+	if best >= 0 {
+		return 5, best, true
+	}
+	return 0, 0, false
+}
+
+/****************************************************/
+// fuzzyGaps reports whether the letters of query appear in s in order,
+// and how many letters of s had to be skipped between the first and
+// the last one (0 means query is a substring)
+/****************************************************/
+func fuzzyGaps(s, query string) (int, bool) {
+	if query == "" {
+		return 0, false
+	}
+
+	start, qi := -1, 0
+	q := []rune(query)
+	for i, r := range []rune(s) {
+		if r != q[qi] {
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+		qi++
+		if qi == len(q) {
+			return i - start + 1 - len(q), true
+		}
+	}
+	return 0, false
+}
+
+/****************************************************/
+// searchPackages runs query against every enabled repository's index,
+// substring searches that find nothing fall back to fuzzy
+/****************************************************/
+func searchPackages(query, mode string) ([]searchResult, error) {
+	repos, err := LoadRepos(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var re *regexp.Regexp
+	if mode == searchRegex {
+		if re, err = regexp.Compile("(?i)" + query); err != nil {
+			return nil, fmt.Errorf("invalid regular expression: %v", err)
+		}
+	}
+
+	var indexes []RepoIndex
+	var order []RepoConfig
+	for _, repo := range sortedRepos(repos) {
+		if repo.Disabled {
+			continue
+		}
+		index, err := loadRepoIndex(repo)
+		if err != nil {
+			eyes.Warnf("Skipping %s: %v", repo.Name, err)
+			continue
+		}
+		indexes = append(indexes, index)
+		order = append(order, repo)
+	}
+	if len(indexes) == 0 {
+		return nil, fmt.Errorf("no synced repositories, run 'blink sync' first")
+	}
+
+	installed := make(map[string]InstalledPkg)
+	if db, err := packageDB(); err == nil {
+		for _, p := range db.Installed {
+			installed[p.Name] = p
+		}
+	} else {
+		eyes.Warnf("Could not read the manifest, installed packages are not marked: %v", err)
+	}
+
+	// first repository to have a name wins, like resolveRecipe. taken
+	// from every entry, a match in one repository can still be shadowed
+	// by another one whose entry didn't match
+	winner := make(map[string]string)
+	for i, index := range indexes {
+		for _, e := range index.Packages {
+			if winner[e.Name] == "" {
+				winner[e.Name] = order[i].Name
+			}
+		}
+	}
+
+	run := func(mode string) []searchResult {
+		var results []searchResult
+		for i, index := range indexes {
+			for _, e := range index.Packages {
+				tier, score, ok := matchEntry(e, strings.ToLower(query), mode, re)
+				if !ok {
+					continue
+				}
+
+				// only mark the repository it was installed from, if known
+				inst, isInst := installed[e.Name]
+				version := ""
+				if isInst && (inst.Repo == "" || inst.Repo == order[i].Name) {
+					version = inst.evr().String()
+				}
+
+				shadowedBy := ""
+				if winner[e.Name] != order[i].Name {
+					shadowedBy = winner[e.Name]
+				}
+
+				results = append(results, searchResult{
+					Repo:        order[i].Name,
+					Name:        e.Name,
+					Version:     e.evr().String(),
+					Description: e.Description,
+					License:     e.License,
+					Installed:   version,
+					Overlay:     order[i].Type == repoTypeLocal,
+					Shadowed:    shadowedBy != "",
+					ShadowedBy:  shadowedBy,
+					tier:        tier,
+					score:       score,
+					order:       i,
+				})
+			}
+		}
+		return results
+	}
+
+	results := run(mode)
+	if len(results) == 0 && mode == searchSubstring {
+		if results = run(searchFuzzy); len(results) > 0 {
+			eyes.Infof("No exact matches for %q, showing fuzzy matches.", query)
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.tier != b.tier {
+			return a.tier < b.tier
+		}
+		if a.score != b.score {
+			return a.score < b.score
+		}
+		if len(a.Name) != len(b.Name) {
+			return len(a.Name) < len(b.Name)
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.order < b.order
+	})
+
+	return results, nil
+}
+
+/****************************************************/
+// showSearch prints the results of searchPackages
+/****************************************************/
+func showSearch(query, mode string, asJSON bool) error {
+	results, err := searchPackages(query, mode)
+	if err != nil {
+		return err
+	}
+
+	if asJSON {
+		if results == nil {
+			results = []searchResult{}
+		}
+		return printJSON(results)
+	}
+
+	if len(results) == 0 {
+		eyes.Infof("No packages match %q.", query)
+		return nil
+	}
+
+	for _, r := range results {
+		line := fmt.Sprintf("%s/%s %s", r.Repo, r.Name, r.Version)
+		switch {
+		case r.Installed == "":
+		case r.Installed == r.Version:
+			line += " [installed]"
+		default:
+			line += fmt.Sprintf(" [installed: %s]", r.Installed)
+		}
+		if r.Overlay {
+			line += " [overlay]"
+		}
+		if r.Shadowed {
+			line += fmt.Sprintf(" (shadowed by %s)", r.ShadowedBy)
+		}
+		fmt.Println(line)
+
+		if r.Description != "" {
+			fmt.Printf("    %s\n", r.Description)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,447 @@
+/*
+  Blink, a powerful source-based package manager. Core of ApertureOS.
+	Want to use it for your own project?
+	Blink is completely FOSS (Free and Open Source),
+	edit, publish, use, contribute to Blink however you prefer.
+  Copyright (C) 2025-2026 Aperture OS
+
+  This program is free software: you can redistribute it and/or modify
+  it under the terms of the Apache 2.0 License as published by
+  the Apache Software Foundation, either version 2.0 of the License, or
+  any later version.
+
+  This program is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <https://www.apache.org/licenses/LICENSE-2.0>.
+*/
+
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Aperture-OS/eyes"
+)
+
+/****************************************************/
+// Signed repositories: a repository ships INDEX (a RepoIndex, see
+// index.go, listing the sha256 of every recipe) and INDEX.sig, the
+// base64 ed25519 signature of INDEX made with its maintainer's key
+//
+// a repository marked signed = true in config.toml must carry a valid
+// signature by one of the keys in trustedKeysPath, any repository that
+// ships INDEX.sig gets checked too. sync checks the signature and every
+// recipe hash after pulling and goes back to the previous commit if
+// they don't match, getpkg checks them again before copying a recipe
+//
+// trustedKeysPath/<name>.pub   base64 public key (blink key add)
+// <name>.key                   base64 private key (blink key generate),
+//                              stays with the maintainer
+/****************************************************/
+
+const (
+	repoIndexFile = "INDEX"
+	repoSigFile   = "INDEX.sig"
+)
+
+// trustedKey is one key of trustedKeysPath
+type trustedKey struct {
+	Name        string            `json:"name"`
+	Fingerprint string            `json:"fingerprint"`
+	Key         ed25519.PublicKey `json:"-"`
+}
+
+// keyFingerprint is the short form keys are shown as
+func keyFingerprint(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:8])
+}
+
+// decodeKey reads a base64 key of the given size
+func decodeKey(data []byte, size int) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("not a base64 key: %v", err)
+	}
+	if len(key) != size {
+		return nil, fmt.Errorf("wrong key size %d, expected %d", len(key), size)
+	}
+	return key, nil
+}
+
+/****************************************************/
+// loadTrustedKeys returns every key in trustedKeysPath, sorted by name
+/****************************************************/
+func loadTrustedKeys() ([]trustedKey, error) {
+	entries, err := os.ReadDir(trustedKeysPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []trustedKey
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".pub") {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(trustedKeysPath, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		key, err := decodeKey(data, ed25519.PublicKeySize)
+		if err != nil {
+			eyes.Warnf("Ignoring trusted key %s: %v", e.Name(), err)
+			continue
+		}
+
+		keys = append(keys, trustedKey{
+			Name:        strings.TrimSuffix(e.Name(), ".pub"),
+			Fingerprint: keyFingerprint(key),
+			Key:         key,
+		})
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Name < keys[j].Name })
+	return keys, nil
+}
+
+/****************************************************/
+// addTrustedKey trusts a public key, src is a .pub file or the base64
+// key itself
+/****************************************************/
+func addTrustedKey(name, src string) error {
+	if !repoNameRe.MatchString(name) {
+		return fmt.Errorf("invalid key name %q (letters, digits, '-' and '_' only)", name)
+	}
+
+	data, err := os.ReadFile(src)
+	if err != nil {
+		data = []byte(src)
+	}
+	key, err := decodeKey(data, ed25519.PublicKeySize)
+	if err != nil {
+		return fmt.Errorf("%s is neither a readable key file nor a key: %v", src, err)
+	}
+
+	dest := filepath.Join(trustedKeysPath, name+".pub")
+	if _, err := os.Stat(dest); err == nil {
+		return fmt.Errorf("a key named %s is already trusted, remove it first", name)
+	}
+
+	keys, err := loadTrustedKeys()
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if k.Key.Equal(ed25519.PublicKey(key)) {
+			return fmt.Errorf("this key is already trusted as %s", k.Name)
+		}
+	}
+
+	if err := os.MkdirAll(trustedKeysPath, 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(dest, []byte(base64.StdEncoding.EncodeToString(key)+"\n"), 0644); err != nil {
+		return err
+	}
+
+	eyes.Successf("Trusted key %s (%s).", name, keyFingerprint(key))
+	return nil
+}
+
+/****************************************************/
+// removeTrustedKey stops trusting a key, repositories signed only by it
+// stop syncing
+/****************************************************/
+func removeTrustedKey(name string) error {
+	dest := filepath.Join(trustedKeysPath, name+".pub")
+	if !repoNameRe.MatchString(name) {
+		return fmt.Errorf("invalid key name %q", name)
+	}
+	if _, err := os.Stat(dest); os.IsNotExist(err) {
+		return fmt.Errorf("no trusted key named %s", name)
+	}
+
+	if err := os.Remove(dest); err != nil {
+		return err
+	}
+
+	eyes.Successf("Removed trusted key %s.", name)
+	return nil
+}
+
+/****************************************************/
+// listTrustedKeys prints the trusted keys
+/****************************************************/
+func listTrustedKeys(asJSON bool) error {
+	keys, err := loadTrustedKeys()
+	if err != nil {
+		return err
+	}
+
+	if asJSON {
+		if keys == nil {
+			keys = []trustedKey{}
+		}
+		return printJSON(keys)
+	}
+
+	if len(keys) == 0 {
+		eyes.Infof("No trusted keys, add one with 'blink key add <name> <file>'.")
+		return nil
+	}
+
+	for _, k := range keys {
+		fmt.Printf("%s  %s\n", k.Fingerprint, k.Name)
+	}
+	return nil
+}
+
+/****************************************************/
+// generateKeyPair writes <name>.key and <name>.pub to the current
+// directory, for repository maintainers
+/****************************************************/
+func generateKeyPair(name string) error {
+	if !repoNameRe.MatchString(name) {
+		return fmt.Errorf("invalid key name %q (letters, digits, '-' and '_' only)", name)
+	}
+
+	for _, f := range []string{name + ".key", name + ".pub"} {
+		if _, err := os.Stat(f); err == nil {
+			return fmt.Errorf("%s already exists", f)
+		}
+	}
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(name+".key", []byte(base64.StdEncoding.EncodeToString(priv)+"\n"), 0600); err != nil {
+		return err
+	}
+	if err := os.WriteFile(name+".pub", []byte(base64.StdEncoding.EncodeToString(pub)+"\n"), 0644); err != nil {
+		return err
+	}
+
+	eyes.Successf("Wrote %s.key (keep it secret) and %s.pub (%s).", name, name, keyFingerprint(pub))
+	return nil
+}
+
+/****************************************************/
+// signRepo indexes a repository checkout and signs the index, for
+// maintainers: commit INDEX and INDEX.sig along with the recipes
+/****************************************************/
+func signRepo(dir, keyFile string) error {
+	index, indexData, err := publishableIndex(dir)
+	if err != nil {
+		return err
+	}
+
+	sig, fingerprint, err := signIndex(indexData, keyFile)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, repoIndexFile), indexData, 0644); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, repoSigFile), sig, 0644); err != nil {
+		return err
+	}
+
+	eyes.Successf("Signed %d recipe(s) with %s.", len(index.Packages), fingerprint)
+	return nil
+}
+
+// publishableIndex indexes dir for publishing, a recipe that can't be
+// read is an error here rather than skipped
+func publishableIndex(dir string) (RepoIndex, []byte, error) {
+	index, problems, err := buildRepoIndex(dir)
+	if err != nil {
+		return RepoIndex{}, nil, err
+	}
+	if len(problems) > 0 {
+		for _, p := range problems {
+			eyes.Errorf("%s", p)
+		}
+		return RepoIndex{}, nil, fmt.Errorf("%d recipe(s) could not be read", len(problems))
+	}
+
+	data, err := json.MarshalIndent(index, "", "  ")
+	return index, data, err
+}
+
+// signIndex signs indexData with the private key in keyFile, returning
+// the contents of INDEX.sig and the key's fingerprint
+func signIndex(indexData []byte, keyFile string) ([]byte, string, error) {
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, "", err
+	}
+	key, err := decodeKey(data, ed25519.PrivateKeySize)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %v", keyFile, err)
+	}
+	priv := ed25519.PrivateKey(key)
+
+	sig := ed25519.Sign(priv, indexData)
+	pub := priv.Public().(ed25519.PublicKey)
+	return []byte(base64.StdEncoding.EncodeToString(sig) + "\n"), keyFingerprint(pub), nil
+}
+
+/****************************************************/
+// verifyIndexData checks sigData against indexData with every trusted
+// key, and returns the decoded index and the key that signed it
+/****************************************************/
+func verifyIndexData(indexData, sigData []byte) (RepoIndex, trustedKey, error) {
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sigData)))
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return RepoIndex{}, trustedKey{}, fmt.Errorf("malformed %s", repoSigFile)
+	}
+
+	keys, err := loadTrustedKeys()
+	if err != nil {
+		return RepoIndex{}, trustedKey{}, err
+	}
+	if len(keys) == 0 {
+		return RepoIndex{}, trustedKey{}, fmt.Errorf("no trusted keys, add the repository's key with 'blink key add'")
+	}
+
+	for _, k := range keys {
+		if !ed25519.Verify(k.Key, indexData, sig) {
+			continue
+		}
+
+		var index RepoIndex
+		if err := json.Unmarshal(indexData, &index); err != nil {
+			return RepoIndex{}, trustedKey{}, fmt.Errorf("signed %s is unreadable: %v", repoIndexFile, err)
+		}
+		return index, k, nil
+	}
+
+	return RepoIndex{}, trustedKey{}, fmt.Errorf("%s is not signed by any trusted key", repoIndexFile)
+}
+
+/****************************************************/
+// checkRecipeHash makes sure data is the recipe the index lists at rel
+/****************************************************/
+func checkRecipeHash(index RepoIndex, rel string, data []byte) error {
+	sum := sha256.Sum256(data)
+	for _, e := range index.Packages {
+		if e.Path != rel {
+			continue
+		}
+		if e.Sha256 != hex.EncodeToString(sum[:]) {
+			return fmt.Errorf("%s does not match the index", rel)
+		}
+		return nil
+	}
+	return fmt.Errorf("%s is not in the index", rel)
+}
+
+/****************************************************/
+// verifyRepo checks the synced copy of a repository in dir, returning
+// its signed index. false means the repository is unsigned and wasn't
+// required to be signed, so there was nothing to verify
+/****************************************************/
+func verifyRepo(repo RepoConfig, dir string) (RepoIndex, bool, error) {
+	sigData, err := os.ReadFile(filepath.Join(dir, repoSigFile))
+	if os.IsNotExist(err) {
+		if repo.Signed {
+			return RepoIndex{}, false, fmt.Errorf("repository is marked signed but has no %s", repoSigFile)
+		}
+		return RepoIndex{}, false, nil
+	}
+	if err != nil {
+		return RepoIndex{}, false, err
+	}
+
+	indexData, err := os.ReadFile(filepath.Join(dir, repoIndexFile))
+	if err != nil {
+		return RepoIndex{}, false, fmt.Errorf("has %s but no readable %s: %v", repoSigFile, repoIndexFile, err)
+	}
+
+	index, key, err := verifyIndexData(indexData, sigData)
+	if err != nil {
+		return RepoIndex{}, false, err
+	}
+	if err := checkRepoFiles(index, dir); err != nil {
+		return RepoIndex{}, false, err
+	}
+
+	eyes.Infof("Repository %s is signed by %s (%s).", repo.Name, key.Name, key.Fingerprint)
+	return index, true, nil
+}
+
+/****************************************************/
+// checkRepoFiles makes sure every recipe the index lists is in dir
+// with the listed sha256
+/****************************************************/
+func checkRepoFiles(index RepoIndex, dir string) error {
+	for _, e := range index.Packages {
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(e.Path)))
+		if err != nil {
+			return fmt.Errorf("%s is in the index but missing: %v", e.Path, err)
+		}
+		if err := checkRecipeHash(index, e.Path, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/****************************************************/
+// readRecipe returns the recipe of name in repo, for signed
+// repositories only after checking it against the signed index
+/****************************************************/
+func readRecipe(repo RepoConfig, name string) ([]byte, error) {
+	dir := repoDir(repo)
+
+	data, err := os.ReadFile(recipeFile(repo, name))
+	if err != nil {
+		return nil, err
+	}
+
+	sigData, err := os.ReadFile(filepath.Join(dir, repoSigFile))
+	if os.IsNotExist(err) {
+		if !repo.Signed {
+			return data, nil
+		}
+		return nil, fmt.Errorf("repository %s is marked signed but has no %s", repo.Name, repoSigFile)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	indexData, err := os.ReadFile(filepath.Join(dir, repoIndexFile))
+	if err != nil {
+		return nil, fmt.Errorf("repository %s has %s but no readable %s", repo.Name, repoSigFile, repoIndexFile)
+	}
+
+	index, _, err := verifyIndexData(indexData, sigData)
+	if err != nil {
+		return nil, fmt.Errorf("repository %s: %v", repo.Name, err)
+	}
+	if err := checkRecipeHash(index, name+".json", data); err != nil {
+		return nil, fmt.Errorf("repository %s: %v", repo.Name, err)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,153 @@
+/*
+  Blink, a powerful source-based package manager. Core of ApertureOS.
+	Want to use it for your own project?
+	Blink is completely FOSS (Free and Open Source),
+	edit, publish, use, contribute to Blink however you prefer.
+  Copyright (C) 2025-2026 Aperture OS
+
+  This program is free software: you can redistribute it and/or modify
+  it under the terms of the Apache 2.0 License as published by
+  the Apache Software Foundation, either version 2.0 of the License, or
+  any later version.
+
+  This program is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <https://www.apache.org/licenses/LICENSE-2.0>.
+*/
+
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// withTrustedKey points trustedKeysPath at a temporary directory that
+// trusts one new key, and returns its private half
+func withTrustedKey(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	old := trustedKeysPath
+	trustedKeysPath = t.TempDir()
+	t.Cleanup(func() { trustedKeysPath = old })
+
+	data := []byte(base64.StdEncoding.EncodeToString(pub) + "\n")
+	if err := os.WriteFile(filepath.Join(trustedKeysPath, "test.pub"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return priv
+}
+
+func sign(priv ed25519.PrivateKey, data []byte) []byte {
+	return []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(priv, data)) + "\n")
+}
+
+func testIndex(t *testing.T, recipe []byte) []byte {
+	t.Helper()
+
+	sum := sha256.Sum256(recipe)
+	data, err := json.Marshal(RepoIndex{Packages: []IndexEntry{
+		{Name: "foo", Version: "1.0", Path: "foo.json", Sha256: hex.EncodeToString(sum[:])},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestVerifyIndexData(t *testing.T) {
+	priv := withTrustedKey(t)
+	indexData := testIndex(t, []byte(`{"name": "foo"}`))
+
+	_, otherPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := []byte(strings.Replace(string(indexData), `"1.0"`, `"1.1"`, 1))
+
+	tests := []struct {
+		name      string
+		indexData []byte
+		sigData   []byte
+		wantErr   bool
+	}{
+		{"signed", indexData, sign(priv, indexData), false},
+		{"tampered index", tampered, sign(priv, indexData), true},
+		{"untrusted key", indexData, sign(otherPriv, indexData), true},
+		{"unsigned", indexData, nil, true},
+		{"malformed signature", indexData, []byte("not a signature"), true},
+		{"truncated signature", indexData, sign(priv, indexData)[:40], true},
+	}
+
+	for _, tt := range tests {
+		index, key, err := verifyIndexData(tt.indexData, tt.sigData)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: verified, want an error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if key.Name != "test" || len(index.Packages) != 1 || index.Packages[0].Name != "foo" {
+			t.Errorf("%s: got key %s and index %+v", tt.name, key.Name, index)
+		}
+	}
+}
+
+func TestVerifyIndexDataNoTrustedKeys(t *testing.T) {
+	priv := withTrustedKey(t)
+	indexData := testIndex(t, []byte(`{"name": "foo"}`))
+	if err := os.Remove(filepath.Join(trustedKeysPath, "test.pub")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := verifyIndexData(indexData, sign(priv, indexData)); err == nil {
+		t.Error("verified without any trusted key")
+	}
+}
+
+func TestCheckRecipeHash(t *testing.T) {
+	recipe := []byte(`{"name": "foo"}`)
+
+	var index RepoIndex
+	if err := json.Unmarshal(testIndex(t, recipe), &index); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		rel     string
+		data    []byte
+		wantErr bool
+	}{
+		{"matching", "foo.json", recipe, false},
+		{"tampered", "foo.json", []byte(`{"name": "foo", "sources": []}`), true},
+		{"not listed", "bar.json", recipe, true},
+	}
+
+	for _, tt := range tests {
+		err := checkRecipeHash(index, tt.rel, tt.data)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: checkRecipeHash = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+/*
+  Blink, a powerful source-based package manager. Core of ApertureOS.
+	Want to use it for your own project?
+	Blink is completely FOSS (Free and Open Source),
+	edit, publish, use, contribute to Blink however you prefer.
+  Copyright (C) 2025-2026 Aperture OS
+
+  This program is free software: you can redistribute it and/or modify
+  it under the terms of the Apache 2.0 License as published by
+  the Apache Software Foundation, either version 2.0 of the License, or
+  any later version.
+
+  This program is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <https://www.apache.org/licenses/LICENSE-2.0>.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+/****************************************************/
+// spdxLicenses is the list of SPDX license identifiers blink lint accepts
+// it's not the full SPDX list (that's 600+ entries), just the ones that
+// actually show up in software people package. if your license is missing
+// add it here (https://spdx.org/licenses/) or use LicenseRef-<something>
+/****************************************************/
+var spdxLicenses = map[string]bool{
+	"0BSD": true, "AFL-3.0": true, "AGPL-3.0-only": true, "AGPL-3.0-or-later": true,
+	"Apache-1.1": true, "Apache-2.0": true, "APSL-2.0": true, "Artistic-1.0": true,
+	"Artistic-1.0-Perl": true, "Artistic-2.0": true, "BlueOak-1.0.0": true,
+	"BSD-1-Clause": true, "BSD-2-Clause": true, "BSD-2-Clause-Patent": true,
+	"BSD-3-Clause": true, "BSD-3-Clause-Clear": true, "BSD-4-Clause": true,
+	"BSL-1.0": true, "bzip2-1.0.6": true, "CC0-1.0": true, "CC-BY-3.0": true,
+	"CC-BY-4.0": true, "CC-BY-SA-3.0": true, "CC-BY-SA-4.0": true, "CDDL-1.0": true,
+	"CDDL-1.1": true, "CECILL-2.1": true, "CPL-1.0": true, "curl": true,
+	"ECL-2.0": true, "EPL-1.0": true, "EPL-2.0": true, "EUPL-1.1": true,
+	"EUPL-1.2": true, "FSFAP": true, "FSFUL": true, "FSFULLR": true, "FTL": true,
+	"GFDL-1.3-only": true, "GFDL-1.3-or-later": true,
+	"GPL-1.0-only": true, "GPL-1.0-or-later": true, "GPL-2.0-only": true,
+	"GPL-2.0-or-later": true, "GPL-3.0-only": true, "GPL-3.0-or-later": true,
+	"HPND": true, "ICU": true, "IJG": true, "ISC": true, "LGPL-2.0-only": true,
+	"LGPL-2.0-or-later": true, "LGPL-2.1-only": true, "LGPL-2.1-or-later": true,
+	"LGPL-3.0-only": true, "LGPL-3.0-or-later": true, "Libpng": true,
+	"libpng-2.0": true, "libtiff": true, "LPPL-1.3c": true, "MirOS": true,
+	"MIT": true, "MIT-0": true, "MIT-CMU": true, "MPL-1.1": true, "MPL-2.0": true,
+	"MPL-2.0-no-copyleft-exception": true, "MS-PL": true, "MS-RL": true,
+	"NCSA": true, "OFL-1.1": true, "OpenSSL": true, "OSL-3.0": true,
+	"PHP-3.01": true, "PostgreSQL": true, "PSF-2.0": true, "Python-2.0": true,
+	"Ruby": true, "SGI-B-2.0": true, "Sleepycat": true, "SMLNJ": true,
+	"TCL": true, "Unicode-3.0": true, "Unicode-DFS-2016": true, "Unlicense": true,
+	"UPL-1.0": true, "Vim": true, "W3C": true, "WTFPL": true, "X11": true,
+	"XFree86-1.1": true, "Zlib": true, "zlib-acknowledgement": true, "ZPL-2.1": true,
+}
+
+// deprecated ids that are still valid SPDX but ambiguous, mapped to a hint
+var spdxDeprecated = map[string]string{
+	"GPL-1.0":   "GPL-1.0-only or GPL-1.0-or-later",
+	"GPL-2.0":   "GPL-2.0-only or GPL-2.0-or-later",
+	"GPL-3.0":   "GPL-3.0-only or GPL-3.0-or-later",
+	"LGPL-2.0":  "LGPL-2.0-only or LGPL-2.0-or-later",
+	"LGPL-2.1":  "LGPL-2.1-only or LGPL-2.1-or-later",
+	"LGPL-3.0":  "LGPL-3.0-only or LGPL-3.0-or-later",
+	"AGPL-3.0":  "AGPL-3.0-only or AGPL-3.0-or-later",
+	"GFDL-1.3":  "GFDL-1.3-only or GFDL-1.3-or-later",
+	"GPL-2.0+":  "GPL-2.0-or-later",
+	"GPL-3.0+":  "GPL-3.0-or-later",
+	"LGPL-2.1+": "LGPL-2.1-or-later",
+}
+
+// exceptions allowed after WITH
+var spdxExceptions = map[string]bool{
+	"Autoconf-exception-3.0": true, "Bison-exception-2.2": true,
+	"Classpath-exception-2.0": true, "GCC-exception-3.1": true,
+	"LLVM-exception": true, "OpenSSL-exception": true,
+	"Font-exception-2.0": true, "Linux-syscall-note": true,
+}
+
+/****************************************************/
+// checkSPDXExpression validates a license expression such as
+// "MIT", "MIT OR Apache-2.0" or "GPL-2.0-or-later WITH Linux-syscall-note"
+// it checks identifiers and operators, not full operator precedence
+/****************************************************/
+func checkSPDXExpression(expr string) error {
+	tokens := strings.Fields(strings.NewReplacer("(", " ( ", ")", " ) ").Replace(expr))
+	if len(tokens) == 0 {
+		return fmt.Errorf("empty license expression")
+	}
+
+	depth := 0
+	expectID := true // alternate between identifier and operator
+	afterWith := false
+
+	for _, tok := range tokens {
+		switch tok {
+		case "(":
+			if !expectID {
+				return fmt.Errorf("unexpected '(' in %q", expr)
+			}
+			depth++
+		case ")":
+			if expectID || depth == 0 {
+				return fmt.Errorf("unexpected ')' in %q", expr)
+			}
+			depth--
+		case "AND", "OR", "WITH":
+			if expectID {
+				return fmt.Errorf("unexpected %s in %q", tok, expr)
+			}
+			expectID = true
+			afterWith = tok == "WITH"
+		default:
+			if !expectID {
+				return fmt.Errorf("missing AND/OR before %q in %q", tok, expr)
+			}
+			if afterWith {
+				if !spdxExceptions[tok] {
+					return fmt.Errorf("unknown SPDX license exception %q", tok)
+				}
+			} else if err := checkSPDXID(tok); err != nil {
+				return err
+			}
+			expectID = false
+			afterWith = false
+		}
+	}
+
+	if expectID || depth != 0 {
+		return fmt.Errorf("incomplete license expression %q", expr)
+	}
+	return nil
+}
+
+func checkSPDXID(id string) error {
+	if strings.HasPrefix(id, "LicenseRef-") && len(id) > len("LicenseRef-") {
+		return nil
+	}
+	if hint, ok := spdxDeprecated[id]; ok {
+		return fmt.Errorf("%q is a deprecated SPDX identifier, use %s", id, hint)
+	}
+	if spdxLicenses[id] {
+		return nil
+	}
+	// "+" means "or later" on old-style ids
+	if strings.HasSuffix(id, "+") && spdxLicenses[strings.TrimSuffix(id, "+")] {
+		return nil
+	}
+	for known := range spdxLicenses {
+		if strings.EqualFold(known, id) {
+			return fmt.Errorf("unknown SPDX license %q, did you mean %q?", id, known)
+		}
+	}
+	return fmt.Errorf("unknown SPDX license %q (use LicenseRef-<name> for custom licenses)", id)
+}
@@ -20,11 +20,14 @@
 
 package main
 
+import "time"
+
 /****************************************************/
 // PackageInfo represents the JSON structure of a package recipe
 /****************************************************/
 type PackageInfo struct {
 	Name        string   `json:"name"`        // Package name
+	Epoch       int      `json:"epoch"`       // Optional epoch, overrides version ordering
 	Version     string   `json:"version"`     // Package version
 	Release     int      `json:"release"`     // Release number
 	Description string   `json:"description"` // Short description
@@ -48,31 +51,74 @@ type PackageInfo struct {
 		Prepare   []string          `json:"prepare"`   // Commands to prepare build
 		Install   []string          `json:"install"`   // Commands to install package
 		Uninstall []string          `json:"uninstall"` // Commands to uninstall package
+		Config    []string          `json:"config"`    // Config files (globs), user edits are expected
 	} `json:"build"`
 }
 
 /****************************************************/
 // Manifest represents Blink's installed package database
+// Schema is bumped whenever the layout changes, loadManifest
+// migrates older manifests (see migrateManifest)
 /****************************************************/
 
 type Manifest struct {
-	Installed []InstalledPkg `json:"installed"`
+	Schema    int            `toml:"schema" json:"schema"`
+	Holds     []Hold         `toml:"holds,omitempty" json:"holds,omitempty"`
+	Installed []InstalledPkg `toml:"installed" json:"installed"`
+}
+
+/****************************************************/
+// Hold freezes a package, blink update and dependency upgrades leave
+// it alone. Version pins it to one exact version, empty means
+// "whatever is installed right now"
+/****************************************************/
+type Hold struct {
+	Name    string    `toml:"name" json:"name"`
+	Version string    `toml:"version,omitempty" json:"version,omitempty"`
+	Since   time.Time `toml:"since" json:"since"`
 }
 
+// install reasons stored in InstalledPkg.Reason
+const (
+	reasonExplicit   = "explicit"   // the user asked for it
+	reasonDependency = "dependency" // pulled in by handleMandatoryDeps/handleOptionalDeps
+)
+
 /****************************************************/
 // InstalledPkg represents a package entry in the manifest
 /****************************************************/
 type InstalledPkg struct {
-	Name    string `json:"name"`
-	Version string `json:"version"`
-	Release int64  `json:"release"`
+	Name        string         `toml:"name" json:"name"`
+	Epoch       int            `toml:"epoch" json:"epoch"`
+	Version     string         `toml:"version" json:"version"`
+	Release     int64          `toml:"release" json:"release"`
+	Reason      string         `toml:"reason" json:"reason"`                             // explicit or dependency
+	InstalledAt time.Time      `toml:"installed_at" json:"installed_at"`                 // first install
+	UpdatedAt   time.Time      `toml:"updated_at" json:"updated_at"`                     // last (re)install
+	Repo        string         `toml:"repo,omitempty" json:"repo,omitempty"`             // repository the recipe came from
+	Commit      string         `toml:"commit,omitempty" json:"commit,omitempty"`         // repository commit at install time
+	OptDeps     []OptSelection `toml:"opt_deps,omitempty" json:"opt_deps,omitempty"`     // optional dependency choices
+	Downgraded  bool           `toml:"downgraded,omitempty" json:"downgraded,omitempty"` // older than the version it replaced
+}
+
+/****************************************************/
+// OptSelection is the choice made for one optional dependency group
+// Choice is empty when the user picked "None"
+/****************************************************/
+type OptSelection struct {
+	Group  int    `toml:"group" json:"group"`
+	Choice string `toml:"choice" json:"choice"`
 }
 
 /****************************************************/
 // RepoConfig represents the structure of the config.toml
 /****************************************************/
 type RepoConfig struct {
-	Name string
-	URL  string
-	Ref  string
+	Name     string
+	Type     string // repoTypeGit, repoTypeHTTP or repoTypeLocal
+	URL      string // clone URL, base URL, or directory for local repositories
+	Ref      string
+	Priority int  // higher wins when several repositories have a package
+	Disabled bool // kept in the config, but not synced or resolved from
+	Signed   bool // must carry an index signed by a trusted key, see signing.go
 }
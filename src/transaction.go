@@ -0,0 +1,640 @@
+/*
+  Blink, a powerful source-based package manager. Core of ApertureOS.
+	Want to use it for your own project?
+	Blink is completely FOSS (Free and Open Source),
+	edit, publish, use, contribute to Blink however you prefer.
+  Copyright (C) 2025-2026 Aperture OS
+
+  This program is free software: you can redistribute it and/or modify
+  it under the terms of the Apache 2.0 License as published by
+  the Apache Software Foundation, either version 2.0 of the License, or
+  any later version.
+
+  This program is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <https://www.apache.org/licenses/LICENSE-2.0>.
+*/
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/Aperture-OS/eyes"
+)
+
+/****************************************************/
+// Transactions: every install, update or uninstall (including all the
+// dependencies it pulls in) runs inside one transaction. before Blink
+// touches a path on the system or a package database entry, it writes
+// a step to the journal under journalPath, backing up whatever was there.
+// if anything fails, the steps are undone in reverse order and the
+// manifest snapshot taken at the start is put back, so code running
+// inside one returns errors instead of calling eyes.Fatalf. if Blink
+// crashes mid-way, the journal stays on disk and the next start rolls
+// it back before running any command (see recoverAtStartup)
+//
+// journalPath/
+//   transaction.json   what is running, and which pid runs it
+//   manifest.toml      manifest as it was before the transaction (if any)
+//   steps.jsonl        one journalStep per line, append only
+//   files/<n>          backups of replaced/removed files
+//   db/<pkg>/          backups of package database directories
+/****************************************************/
+
+// journal step operations
+const (
+	stepCreate  = "create"  // path didn't exist, undo = remove it
+	stepReplace = "replace" // path existed and gets overwritten, undo = restore backup
+	stepRemove  = "remove"  // path gets deleted, undo = restore backup
+	stepDB      = "db"      // package database dir gets changed, undo = restore backup
+)
+
+// journalStep is one line of steps.jsonl
+type journalStep struct {
+	Op      string `json:"op"`
+	Path    string `json:"path,omitempty"`
+	Type    string `json:"type,omitempty"`    // type of the original path (file, dir, symlink)
+	Mode    uint32 `json:"mode,omitempty"`    // mode of the original path
+	Target  string `json:"target,omitempty"`  // original symlink target
+	Backup  string `json:"backup,omitempty"`  // backup file/dir inside the journal
+	Package string `json:"package,omitempty"` // db steps only
+}
+
+// transactionInfo is transaction.json
+type transactionInfo struct {
+	Kind     string    `json:"kind"`
+	Packages []string  `json:"packages"`
+	PID      int       `json:"pid"`
+	Started  time.Time `json:"started"`
+}
+
+/****************************************************/
+// transaction is the currently running transaction, there's only
+// ever one (see currentTx), nested install() calls for dependencies
+// just keep appending to it
+/****************************************************/
+type transaction struct {
+	dir       string
+	steps     *os.File
+	backups   int
+	dbTouched map[string]bool
+	pathsSeen map[string]bool
+	db        *PackageDB // loaded on first use, see packageDB
+}
+
+var currentTx *transaction
+
+/****************************************************/
+// withTransaction runs fn inside a transaction: rolls back a crashed
+// one first, begins, runs fn, then commits or rolls back. a committed
+// transaction becomes a new generation (see generations.go)
+/****************************************************/
+func withTransaction(kind string, pkgs []string, fn func() error) error {
+	if currentTx != nil {
+		return fn() // already inside one
+	}
+
+	if err := recoverTransaction(); err != nil {
+		return err
+	}
+
+	// first transaction ever, keep what was there before as generation 1
+	if gens, err := listGenerations(); err == nil && len(gens) == 0 {
+		if err := recordGeneration("initial", nil); err != nil {
+			eyes.Warnf("Could not record the initial generation: %v", err)
+		}
+	}
+
+	tx, err := beginTransaction(kind, pkgs)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+	currentTx = tx
+	defer func() { currentTx = nil }()
+
+	// the in-memory package database goes to disk before the journal
+	// is thrown away, a crash in between still rolls everything back
+	err = fn()
+	if err == nil && tx.db != nil {
+		err = tx.db.Flush()
+	}
+
+	if err != nil {
+		eyes.Errorf("Transaction failed, rolling back: %v", err)
+		if rbErr := tx.rollback(); rbErr != nil {
+			return fmt.Errorf("%v (rollback also failed: %v, journal kept at %s)", err, rbErr, tx.dir)
+		}
+		eyes.Warnf("Rollback completed, system restored to its state before the %s.", kind)
+		return err
+	}
+
+	if err := tx.commit(); err != nil {
+		return err
+	}
+
+	// the transaction is done either way, a missing snapshot only warns
+	if err := recordGeneration(kind, pkgs); err != nil {
+		eyes.Warnf("Could not record a new generation: %v", err)
+	}
+	return nil
+}
+
+/****************************************************/
+// beginTransaction creates the journal and snapshots the manifest
+/****************************************************/
+func beginTransaction(kind string, pkgs []string) (*transaction, error) {
+	if err := os.MkdirAll(filepath.Join(journalPath, "files"), 0700); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Join(journalPath, "db"), 0700); err != nil {
+		return nil, err
+	}
+
+	// manifest snapshot, a missing manifest simply isn't copied
+	if _, err := os.Stat(manifestPath); err == nil {
+		if err := copyFileAtomic(manifestPath, filepath.Join(journalPath, "manifest.toml"), 0644); err != nil {
+			return nil, err
+		}
+		if err := syncTree(filepath.Join(journalPath, "manifest.toml")); err != nil {
+			return nil, err
+		}
+	}
+
+	info, err := json.MarshalIndent(transactionInfo{
+		Kind:     kind,
+		Packages: pkgs,
+		PID:      os.Getpid(),
+		Started:  time.Now(),
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writeFileSync(filepath.Join(journalPath, "transaction.json"), info); err != nil {
+		return nil, err
+	}
+
+	steps, err := os.OpenFile(filepath.Join(journalPath, "steps.jsonl"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := syncDir(journalPath); err != nil {
+		steps.Close()
+		return nil, err
+	}
+
+	eyes.Infof("Started %s transaction (journal at %s)", kind, journalPath)
+
+	return &transaction{
+		dir:       journalPath,
+		steps:     steps,
+		dbTouched: make(map[string]bool),
+		pathsSeen: make(map[string]bool),
+	}, nil
+}
+
+/****************************************************/
+// commit throws the journal away, everything went fine
+/****************************************************/
+func (tx *transaction) commit() error {
+	tx.steps.Close()
+	if err := os.RemoveAll(tx.dir); err != nil {
+		return fmt.Errorf("failed to remove journal: %v", err)
+	}
+	eyes.Infof("Transaction committed.")
+	return nil
+}
+
+// log appends a step to the journal. written (and synced) before the
+// change it describes, so a crash right after still knows what to undo
+func (tx *transaction) log(step journalStep) error {
+	data, err := json.Marshal(step)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.steps.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write journal: %v", err)
+	}
+	if err := tx.steps.Sync(); err != nil {
+		return fmt.Errorf("failed to sync journal: %v", err)
+	}
+	return nil
+}
+
+// backupTarget copies a file or symlink into the journal and returns the step
+// describing it, directories only need their mode
+func (tx *transaction) backupTarget(op, target string, info os.FileInfo) (journalStep, error) {
+	step := journalStep{
+		Op:   op,
+		Path: target,
+		Mode: uint32(info.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)),
+	}
+
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		linkTarget, err := os.Readlink(target)
+		if err != nil {
+			return step, err
+		}
+		step.Type = fileTypeSymlink
+		step.Target = linkTarget
+
+	case info.IsDir():
+		step.Type = fileTypeDir
+
+	default:
+		tx.backups++
+		step.Type = fileTypeFile
+		step.Backup = filepath.Join(tx.dir, "files", strconv.Itoa(tx.backups))
+		if err := copyFileAtomic(target, step.Backup, info.Mode()); err != nil {
+			return step, fmt.Errorf("failed to back up %s: %v", target, err)
+		}
+		if err := syncTree(step.Backup); err != nil {
+			return step, fmt.Errorf("failed to sync backup of %s: %v", target, err)
+		}
+		if err := syncDir(filepath.Dir(step.Backup)); err != nil {
+			return step, fmt.Errorf("failed to sync backup of %s: %v", target, err)
+		}
+	}
+
+	return step, nil
+}
+
+/****************************************************/
+// journalBeforeWrite must be called before a path on the system is
+// created or overwritten. the first write to a path in a transaction
+// is what matters, later ones would just back up our own files
+/****************************************************/
+func journalBeforeWrite(target string) error {
+	tx := currentTx
+	if tx == nil || tx.pathsSeen[target] {
+		return nil
+	}
+	tx.pathsSeen[target] = true
+
+	info, err := os.Lstat(target)
+	if os.IsNotExist(err) {
+		return tx.log(journalStep{Op: stepCreate, Path: target})
+	}
+	if err != nil {
+		return err
+	}
+
+	step, err := tx.backupTarget(stepReplace, target, info)
+	if err != nil {
+		return err
+	}
+	return tx.log(step)
+}
+
+/****************************************************/
+// journalBeforeRemove must be called before a path is deleted
+/****************************************************/
+func journalBeforeRemove(target string) error {
+	tx := currentTx
+	if tx == nil {
+		return nil
+	}
+
+	info, err := os.Lstat(target)
+	if err != nil {
+		return nil // nothing to remove, nothing to restore
+	}
+
+	// created earlier in this same transaction, undoing the create is enough
+	if tx.pathsSeen[target] {
+		return nil
+	}
+	tx.pathsSeen[target] = true
+
+	step, err := tx.backupTarget(stepRemove, target, info)
+	if err != nil {
+		return err
+	}
+	return tx.log(step)
+}
+
+/****************************************************/
+// journalBeforeDB must be called before a package's database
+// directory (files.toml & co) is written or removed
+/****************************************************/
+func journalBeforeDB(pkgName string) error {
+	tx := currentTx
+	if tx == nil || tx.dbTouched[pkgName] {
+		return nil
+	}
+	tx.dbTouched[pkgName] = true
+
+	step := journalStep{Op: stepDB, Package: pkgName}
+
+	if _, err := os.Stat(pkgDBDir(pkgName)); err == nil {
+		step.Backup = filepath.Join(tx.dir, "db", pkgName)
+		if err := copyDir(pkgDBDir(pkgName), step.Backup); err != nil {
+			return fmt.Errorf("failed to back up database of %s: %v", pkgName, err)
+		}
+		if err := syncTree(step.Backup); err != nil {
+			return fmt.Errorf("failed to sync database backup of %s: %v", pkgName, err)
+		}
+		if err := syncDir(filepath.Dir(step.Backup)); err != nil {
+			return fmt.Errorf("failed to sync database backup of %s: %v", pkgName, err)
+		}
+	}
+
+	return tx.log(step)
+}
+
+/****************************************************/
+// rollback undoes every journaled step, newest first, then puts the
+// manifest snapshot back. it keeps going on errors so one stubborn
+// file doesn't leave everything else half restored
+/****************************************************/
+func (tx *transaction) rollback() error {
+	if tx.steps != nil {
+		tx.steps.Close()
+	}
+
+	steps, err := readJournalSteps(tx.dir)
+	if err != nil {
+		return err
+	}
+
+	eyes.Warnf("Rolling back %d journaled step(s)", len(steps))
+
+	var failed int
+	for i := len(steps) - 1; i >= 0; i-- {
+		if err := undoStep(steps[i]); err != nil {
+			eyes.Warnf("Failed to undo %s %s: %v", steps[i].Op, steps[i].Path+steps[i].Package, err)
+			failed++
+		}
+	}
+
+	// manifest back to how it was
+	snapshot := filepath.Join(tx.dir, "manifest.toml")
+	if _, err := os.Stat(snapshot); err == nil {
+		if err := copyFileAtomic(snapshot, manifestPath, 0644); err != nil {
+			eyes.Warnf("Failed to restore manifest: %v", err)
+			failed++
+		}
+	} else {
+		_ = os.Remove(manifestPath)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d step(s) could not be undone", failed)
+	}
+
+	return os.RemoveAll(tx.dir)
+}
+
+func undoStep(step journalStep) error {
+	switch step.Op {
+	case stepCreate:
+		_ = os.Remove(step.Path + ".blink-new") // leftover of an interrupted copy
+		info, err := os.Lstat(step.Path)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if entries, _ := os.ReadDir(step.Path); len(entries) > 0 {
+				return nil // something else lives in there now
+			}
+		}
+		return os.Remove(step.Path)
+
+	case stepReplace, stepRemove:
+		_ = os.Remove(step.Path + ".blink-new")
+		return restoreJournaled(step)
+
+	case stepDB:
+		if err := os.RemoveAll(pkgDBDir(step.Package)); err != nil {
+			return err
+		}
+		if step.Backup == "" {
+			return nil // didn't exist before
+		}
+		return copyDir(step.Backup, pkgDBDir(step.Package))
+	}
+
+	return fmt.Errorf("unknown journal step %q", step.Op)
+}
+
+// restoreJournaled puts back a file, symlink or directory from a step
+func restoreJournaled(step journalStep) error {
+	if err := os.MkdirAll(filepath.Dir(step.Path), 0755); err != nil {
+		return err
+	}
+
+	mode := os.FileMode(step.Mode)
+
+	switch step.Type {
+	case fileTypeDir:
+		if err := os.MkdirAll(step.Path, mode.Perm()); err != nil {
+			return err
+		}
+		return os.Chmod(step.Path, mode)
+
+	case fileTypeSymlink:
+		if info, err := os.Lstat(step.Path); err == nil && !info.IsDir() {
+			os.Remove(step.Path)
+		}
+		return os.Symlink(step.Target, step.Path)
+
+	default:
+		if info, err := os.Lstat(step.Path); err == nil && info.IsDir() {
+			return fmt.Errorf("%s is a directory now", step.Path)
+		}
+		return copyFileAtomic(step.Backup, step.Path, mode)
+	}
+}
+
+/****************************************************/
+// recoverTransaction rolls back a journal left behind by a crashed
+// Blink. if the pid that wrote it is still alive, another instance is
+// in the middle of its transaction and we refuse to run instead
+/****************************************************/
+func recoverTransaction() error {
+	data, err := os.ReadFile(filepath.Join(journalPath, "transaction.json"))
+	if os.IsNotExist(err) {
+		if _, err := os.Stat(journalPath); err == nil {
+			// crashed before transaction.json was written, nothing was touched yet
+			return os.RemoveAll(journalPath)
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var info transactionInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return fmt.Errorf("corrupted transaction journal at %s: %v", journalPath, err)
+	}
+
+	if info.PID != os.Getpid() && processAlive(info.PID) {
+		return fmt.Errorf("another Blink instance (pid %d) is running a %s transaction", info.PID, info.Kind)
+	}
+
+	eyes.Warnf("Found an unfinished %s transaction of %v from %s, rolling it back.",
+		info.Kind, info.Packages, info.Started.Format(time.RFC1123))
+
+	tx := &transaction{dir: journalPath}
+	if err := tx.rollback(); err != nil {
+		return fmt.Errorf("failed to roll back unfinished transaction: %v", err)
+	}
+
+	eyes.Success("Unfinished transaction rolled back.")
+	return nil
+}
+
+/****************************************************/
+// recoverAtStartup runs before every command, so even read-only ones
+// (list, owns, verify...) don't look at a half-merged system after a
+// crash. without root nothing can be restored, it only warns. a journal
+// whose pid is still alive belongs to a running instance, left alone
+/****************************************************/
+func recoverAtStartup() {
+	if _, err := os.Stat(journalPath); err != nil {
+		return
+	}
+
+	var info transactionInfo
+	if data, err := os.ReadFile(filepath.Join(journalPath, "transaction.json")); err == nil {
+		if json.Unmarshal(data, &info) == nil && info.PID != os.Getpid() && processAlive(info.PID) {
+			return
+		}
+	}
+
+	if os.Geteuid() != 0 {
+		if info.Kind == "" {
+			return // never got as far as touching anything, or unreadable
+		}
+		eyes.Warnf("An unfinished %s transaction was left at %s, installed files may not match the package database. Run Blink as root to roll it back.",
+			info.Kind, journalPath)
+		return
+	}
+
+	if err := recoverTransaction(); err != nil {
+		eyes.Errorf("%v", err)
+	}
+}
+
+func readJournalSteps(dir string) ([]journalStep, error) {
+	f, err := os.Open(filepath.Join(dir, "steps.jsonl"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var steps []journalStep
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		var step journalStep
+		if err := json.Unmarshal(scanner.Bytes(), &step); err != nil {
+			break // torn last line from a crash, everything before it is valid
+		}
+		steps = append(steps, step)
+	}
+
+	return steps, scanner.Err()
+}
+
+// processAlive reports whether a process with this pid exists
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+/****************************************************/
+// writeFileSync writes a file and fsyncs it before returning
+/****************************************************/
+func writeFileSync(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+/****************************************************/
+// syncDir fsyncs a directory, so a rename inside it is on disk too
+/****************************************************/
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+/****************************************************/
+// syncTree fsyncs a file, or a directory and everything in it. backups
+// have to be on disk before what they stand for gets changed
+/****************************************************/
+func syncTree(root string) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return f.Sync()
+	})
+}
+
+/****************************************************/
+// copyDir copies a directory of regular files (recursively)
+// only used for the small package database directories
+/****************************************************/
+func copyDir(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		if info.IsDir() {
+			return os.MkdirAll(target, info.Mode().Perm())
+		}
+		return copyFileAtomic(path, target, info.Mode())
+	})
+}
@@ -79,19 +79,31 @@ func runCmd(name string, args ...string) error {
 /****************************************************/
 
 func compareSHA256(expectedHash, file string) (bool, error) { // takes a expectedHash and a file, it generates the file's sha256 and compares it with expectedHash
-	f, err := os.Open(file)
+	actual, err := fileSHA256(file)
 	if err != nil {
 		return false, err
 	}
+
+	return strings.EqualFold(actual, expectedHash), nil
+}
+
+/****************************************************/
+// fileSHA256 returns the hex encoded sha256 of a file
+/****************************************************/
+
+func fileSHA256(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
 	defer f.Close()
 
 	h := sha256.New()
 	if _, err := io.Copy(h, f); err != nil {
-		return false, err
+		return "", err
 	}
 
-	actual := hex.EncodeToString(h.Sum(nil))
-	return strings.EqualFold(actual, expectedHash), nil
+	return hex.EncodeToString(h.Sum(nil)), nil
 }
 
 /****************************************************/
@@ -0,0 +1,201 @@
+/*
+  Blink, a powerful source-based package manager. Core of ApertureOS.
+	Want to use it for your own project?
+	Blink is completely FOSS (Free and Open Source),
+	edit, publish, use, contribute to Blink however you prefer.
+  Copyright (C) 2025-2026 Aperture OS
+
+  This program is free software: you can redistribute it and/or modify
+  it under the terms of the Apache 2.0 License as published by
+  the Apache Software Foundation, either version 2.0 of the License, or
+  any later version.
+
+  This program is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <https://www.apache.org/licenses/LICENSE-2.0>.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Aperture-OS/eyes"
+)
+
+/****************************************************/
+// verify compares what's on disk with what the file database says
+// was installed: missing files, changed content (sha256), changed
+// permissions, changed symlink targets and files replaced by something
+// of a different type. useful after incidents to spot tampering or an
+// upgrade that died halfway
+/****************************************************/
+
+// kinds of problems verify can report
+const (
+	verifyMissing     = "missing"
+	verifyModified    = "modified"
+	verifyPermissions = "permissions"
+	verifyType        = "type"
+	verifySymlink     = "symlink"
+	verifyRecipe      = "recipe"
+)
+
+// verifyIssue is one problem with one file
+type verifyIssue struct {
+	Package string
+	Path    string
+	Kind    string
+	Detail  string
+	Config  bool
+}
+
+func (v verifyIssue) String() string {
+	s := fmt.Sprintf("%s: %s: %s", v.Package, v.Path, v.Kind)
+	if v.Detail != "" {
+		s += " (" + v.Detail + ")"
+	}
+	if v.Config {
+		s += " [config]"
+	}
+	return s
+}
+
+/****************************************************/
+// verifyPackages checks the given packages, or every installed
+// package when names is empty. configOK skips files marked as config
+/****************************************************/
+func verifyPackages(names []string, configOK bool) ([]verifyIssue, error) {
+	pdb, err := packageDB()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(names) == 0 {
+		for _, inst := range pdb.Installed {
+			names = append(names, inst.Name)
+		}
+	}
+
+	var issues []verifyIssue
+
+	for _, name := range names {
+		installed, ok := pdb.Get(name)
+		if !ok {
+			return nil, fmt.Errorf("package %s is not installed", name)
+		}
+
+		// the recorded recipe has to be the installed version, and its
+		// config globs also cover entries recorded before they existed
+		var configGlobs []string
+		recipe, err := loadRecipeDB(name)
+		switch {
+		case err == os.ErrNotExist:
+			eyes.Warnf("%s was installed before recipes were recorded, reinstall it to record one.", name)
+		case err != nil:
+			issues = append(issues, verifyIssue{Package: name, Path: recipeDBPath(name), Kind: verifyRecipe, Detail: err.Error()})
+		case compareEVR(recipe.evr(), installed.evr()) != 0:
+			issues = append(issues, verifyIssue{
+				Package: name,
+				Path:    recipeDBPath(name),
+				Kind:    verifyRecipe,
+				Detail:  fmt.Sprintf("recorded recipe is %s, manifest says %s", recipe.evr(), installed.evr()),
+			})
+		default:
+			configGlobs = recipe.Build.Config
+		}
+
+		db, err := loadFileDB(name)
+		if err == os.ErrNotExist {
+			eyes.Warnf("%s was installed before file tracking, nothing to verify.", name)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		eyes.Infof("Verifying %s (%d files)", name, len(db.Files))
+
+		for _, f := range db.Files {
+			if !f.Config && matchAnyGlob(configGlobs, f.Path) {
+				f.Config = true
+			}
+			if configOK && f.Config {
+				continue
+			}
+			issues = append(issues, verifyEntry(name, f, installRoot)...)
+		}
+	}
+
+	return issues, nil
+}
+
+/****************************************************/
+// verifyEntry checks a single recorded path against root
+/****************************************************/
+func verifyEntry(pkgName string, f FileEntry, root string) []verifyIssue {
+	issue := func(kind, detail string) verifyIssue {
+		return verifyIssue{Package: pkgName, Path: f.Path, Kind: kind, Detail: detail, Config: f.Config}
+	}
+
+	info, err := os.Lstat(filepath.Join(root, f.Path))
+	if os.IsNotExist(err) {
+		return []verifyIssue{issue(verifyMissing, "")}
+	}
+	if err != nil {
+		return []verifyIssue{issue(verifyMissing, err.Error())}
+	}
+
+	// a symlink standing in for a dir (/lib -> usr/lib) is that dir,
+	// mergeStaged installed into it and recorded its mode
+	if f.Type == fileTypeDir && info.Mode()&os.ModeSymlink != 0 {
+		if dir, err := os.Stat(filepath.Join(root, f.Path)); err == nil && dir.IsDir() {
+			info = dir
+		}
+	}
+
+	actualType := fileTypeFile
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		actualType = fileTypeSymlink
+	case info.IsDir():
+		actualType = fileTypeDir
+	case !info.Mode().IsRegular():
+		actualType = "special"
+	}
+
+	if actualType != f.Type {
+		return []verifyIssue{issue(verifyType, fmt.Sprintf("expected %s, found %s", f.Type, actualType))}
+	}
+
+	var issues []verifyIssue
+
+	switch f.Type {
+	case fileTypeSymlink:
+		target, err := os.Readlink(filepath.Join(root, f.Path))
+		if err != nil || target != f.Target {
+			issues = append(issues, issue(verifySymlink, fmt.Sprintf("expected -> %s, found -> %s", f.Target, target)))
+		}
+		return issues // symlink modes are meaningless
+
+	case fileTypeFile:
+		sum, err := fileSHA256(filepath.Join(root, f.Path))
+		if err != nil {
+			issues = append(issues, issue(verifyModified, err.Error()))
+		} else if sum != f.Sha256 {
+			issues = append(issues, issue(verifyModified, "sha256 mismatch"))
+		}
+	}
+
+	mode := uint32(info.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky))
+	if mode != f.Mode {
+		issues = append(issues, issue(verifyPermissions, fmt.Sprintf("expected %s, found %s", os.FileMode(f.Mode), os.FileMode(mode))))
+	}
+
+	return issues
+}
@@ -0,0 +1,375 @@
+/*
+  Blink, a powerful source-based package manager. Core of ApertureOS.
+	Want to use it for your own project?
+	Blink is completely FOSS (Free and Open Source),
+	edit, publish, use, contribute to Blink however you prefer.
+  Copyright (C) 2025-2026 Aperture OS
+
+  This program is free software: you can redistribute it and/or modify
+  it under the terms of the Apache 2.0 License as published by
+  the Apache Software Foundation, either version 2.0 of the License, or
+  any later version.
+
+  This program is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <https://www.apache.org/licenses/LICENSE-2.0>.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/****************************************************/
+// versionConstraint is a single "<op><version>" requirement
+// taken from a recipe's dependencies map, e.g. ">=3.0"
+// a dependency value like ">=3.0, <4" becomes a constraintSet
+// with two of these, and ALL of them must match
+// the version can carry an epoch ("1:2.0") and a release ("2.0-3"),
+// the release is only compared when the constraint spells it out
+/****************************************************/
+type versionConstraint struct {
+	Op         string     // one of =, !=, <, <=, >, >=
+	Version    pkgVersion // version the op compares against
+	HasRelease bool       // whether the constraint pinned a release
+}
+
+type constraintSet []versionConstraint
+
+// operators ordered longest first so ">=" is not parsed as ">" + "=3.0"
+var constraintOps = []string{">=", "<=", "!=", "==", ">", "<", "="}
+
+/****************************************************/
+// parseConstraints parses a dependency constraint string such as
+// ">=3.0, <4" into a constraintSet. an empty string or "*" means
+// "any version" and returns an empty set. a bare version ("1.2.3")
+// is treated as an exact match, same as "=1.2.3"
+/****************************************************/
+func parseConstraints(s string) (constraintSet, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "*" {
+		return constraintSet{}, nil
+	}
+
+	var set constraintSet
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			return nil, fmt.Errorf("empty constraint in %q", s)
+		}
+
+		op := "="
+		for _, candidate := range constraintOps {
+			if strings.HasPrefix(part, candidate) {
+				op = candidate
+				part = strings.TrimSpace(strings.TrimPrefix(part, candidate))
+				break
+			}
+		}
+		if op == "==" {
+			op = "="
+		}
+
+		if part == "" {
+			return nil, fmt.Errorf("missing version after %q in %q", op, s)
+		}
+		if strings.ContainsAny(part, "<>=! ") {
+			return nil, fmt.Errorf("invalid version %q in %q", part, s)
+		}
+
+		v, hasRelease, err := parseEVR(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version %q in %q: %v", part, s, err)
+		}
+
+		set = append(set, versionConstraint{Op: op, Version: v, HasRelease: hasRelease})
+	}
+
+	return set, nil
+}
+
+/****************************************************/
+// parseEVR splits "[epoch:]version[-release]" into a pkgVersion
+// "-" only ever separates the release: recipe versions can't contain
+// one (lint rejects them, 2024-01-15 is written 2024.01.15), so what
+// follows it must be a number and the version can't have another.
+// "=2024-01-15" is an error instead of version 2024-01 release 15
+/****************************************************/
+func parseEVR(s string) (pkgVersion, bool, error) {
+	var v pkgVersion
+
+	if idx := strings.Index(s, ":"); idx >= 0 {
+		epoch, err := strconv.Atoi(s[:idx])
+		if err != nil || epoch < 0 {
+			return v, false, fmt.Errorf("invalid epoch %q", s[:idx])
+		}
+		v.Epoch = epoch
+		s = s[idx+1:]
+	}
+
+	hasRelease := false
+	if idx := strings.LastIndex(s, "-"); idx >= 0 {
+		rel, err := strconv.ParseInt(s[idx+1:], 10, 64)
+		if err != nil || rel < 0 {
+			return v, false, fmt.Errorf("invalid release %q (\"-\" separates the release)", s[idx+1:])
+		}
+		v.Release = rel
+		hasRelease = true
+		s = s[:idx]
+	}
+
+	if s == "" {
+		return v, false, fmt.Errorf("empty version")
+	}
+	if strings.Contains(s, "-") {
+		return v, false, fmt.Errorf("invalid version %q, versions can't contain \"-\" (it separates the release)", s)
+	}
+	v.Version = s
+
+	return v, hasRelease, nil
+}
+
+/****************************************************/
+// satisfiedBy reports whether v matches every constraint in the set
+// an empty set is satisfied by anything
+/****************************************************/
+func (c constraintSet) satisfiedBy(v pkgVersion) bool {
+	for _, vc := range c {
+		want := vc.Version
+		if !vc.HasRelease {
+			want.Release = v.Release // release not pinned, don't let it decide
+		}
+		cmp := compareEVR(v, want)
+
+		var ok bool
+		switch vc.Op {
+		case "=":
+			ok = cmp == 0
+		case "!=":
+			ok = cmp != 0
+		case "<":
+			ok = cmp < 0
+		case "<=":
+			ok = cmp <= 0
+		case ">":
+			ok = cmp > 0
+		case ">=":
+			ok = cmp >= 0
+		}
+
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// String turns the set back into the recipe syntax, used for log messages
+func (c constraintSet) String() string {
+	if len(c) == 0 {
+		return "*"
+	}
+	parts := make([]string, 0, len(c))
+	for _, vc := range c {
+		v := vc.Version.Version
+		if vc.HasRelease {
+			v = fmt.Sprintf("%s-%d", v, vc.Version.Release)
+		}
+		if vc.Version.Epoch != 0 {
+			v = fmt.Sprintf("%d:%s", vc.Version.Epoch, v)
+		}
+		parts = append(parts, vc.Op+v)
+	}
+	return strings.Join(parts, ", ")
+}
+
+/****************************************************/
+// pkgVersion is the full epoch:version-release of a package
+// epoch wins over everything, then version, then release. recipes
+// that change their versioning scheme (e.g. 2024.1 -> 1.0) bump epoch
+// so the "smaller" new version is still seen as an update
+/****************************************************/
+type pkgVersion struct {
+	Epoch   int
+	Version string
+	Release int64
+}
+
+// evr returns the recipe's epoch:version-release
+func (p PackageInfo) evr() pkgVersion {
+	return pkgVersion{Epoch: p.Epoch, Version: p.Version, Release: int64(p.Release)}
+}
+
+// evr returns the installed package's epoch:version-release
+func (p InstalledPkg) evr() pkgVersion {
+	return pkgVersion{Epoch: p.Epoch, Version: p.Version, Release: p.Release}
+}
+
+// String formats like rpm/pacman do, epoch is left out when it's 0
+func (v pkgVersion) String() string {
+	s := fmt.Sprintf("%s-%d", v.Version, v.Release)
+	if v.Epoch != 0 {
+		s = fmt.Sprintf("%d:%s", v.Epoch, s)
+	}
+	return s
+}
+
+/****************************************************/
+// compareEVR compares two full package versions
+// returns -1 if a < b, 0 if a == b and 1 if a > b
+/****************************************************/
+func compareEVR(a, b pkgVersion) int {
+	switch {
+	case a.Epoch < b.Epoch:
+		return -1
+	case a.Epoch > b.Epoch:
+		return 1
+	}
+
+	if c := compareVersions(a.Version, b.Version); c != 0 {
+		return c
+	}
+
+	switch {
+	case a.Release < b.Release:
+		return -1
+	case a.Release > b.Release:
+		return 1
+	}
+	return 0
+}
+
+/****************************************************/
+// compareVersions compares two version strings and returns
+// -1 if a < b, 0 if a == b and 1 if a > b
+// this is the rpmvercmp algorithm (pacman's vercmp is the same thing):
+//   - versions are split into runs of digits and runs of letters,
+//     anything else is a separator ("1.10rc2" -> 1, 10, rc, 2)
+//   - numbers compare as numbers, letters as strings, and a number
+//     is always newer than letters (1.0.1 > 1.0.a)
+//   - "~" sorts before everything, even the end of the string,
+//     so 1.0~rc1 < 1.0 (pre-releases)
+//   - "^" sorts after the end of the string but before anything else,
+//     so 1.0 < 1.0^git1 < 1.0.1 (post-release snapshots)
+//   - if everything matched, the one with segments left over wins
+/****************************************************/
+func compareVersions(a, b string) int {
+	if a == b {
+		return 0
+	}
+
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		// skip separators
+		for i < len(a) && !isVersionChar(a[i]) {
+			i++
+		}
+		for j < len(b) && !isVersionChar(b[j]) {
+			j++
+		}
+
+		// tilde, pre-release marker
+		if (i < len(a) && a[i] == '~') || (j < len(b) && b[j] == '~') {
+			if i >= len(a) || a[i] != '~' {
+				return 1
+			}
+			if j >= len(b) || b[j] != '~' {
+				return -1
+			}
+			i++
+			j++
+			continue
+		}
+
+		// caret, post-release marker
+		if (i < len(a) && a[i] == '^') || (j < len(b) && b[j] == '^') {
+			if i >= len(a) {
+				return -1
+			}
+			if j >= len(b) {
+				return 1
+			}
+			if a[i] != '^' {
+				return 1
+			}
+			if b[j] != '^' {
+				return -1
+			}
+			i++
+			j++
+			continue
+		}
+
+		if i >= len(a) || j >= len(b) {
+			break
+		}
+
+		// grab the next segment of the same type from both sides
+		si, sj := i, j
+		numeric := isDigit(a[i])
+		if numeric {
+			for i < len(a) && isDigit(a[i]) {
+				i++
+			}
+			for j < len(b) && isDigit(b[j]) {
+				j++
+			}
+		} else {
+			for i < len(a) && isLetter(a[i]) {
+				i++
+			}
+			for j < len(b) && isLetter(b[j]) {
+				j++
+			}
+		}
+
+		segA, segB := a[si:i], b[sj:j]
+
+		// b has a segment of the other type here
+		if segB == "" {
+			if numeric {
+				return 1
+			}
+			return -1
+		}
+
+		if numeric {
+			segA = strings.TrimLeft(segA, "0")
+			segB = strings.TrimLeft(segB, "0")
+
+			// more digits == bigger number, no overflow worries
+			if len(segA) != len(segB) {
+				if len(segA) < len(segB) {
+					return -1
+				}
+				return 1
+			}
+		}
+
+		if c := strings.Compare(segA, segB); c != 0 {
+			return c
+		}
+	}
+
+	switch {
+	case i >= len(a) && j >= len(b):
+		return 0
+	case i >= len(a):
+		return -1
+	}
+	return 1
+}
+
+func isDigit(c byte) bool  { return c >= '0' && c <= '9' }
+func isLetter(c byte) bool { return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') }
+
+// isVersionChar is anything that isn't a separator
+func isVersionChar(c byte) bool {
+	return isDigit(c) || isLetter(c) || c == '~' || c == '^'
+}
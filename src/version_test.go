@@ -0,0 +1,166 @@
+/*
+  Blink, a powerful source-based package manager. Core of ApertureOS.
+	Want to use it for your own project?
+	Blink is completely FOSS (Free and Open Source),
+	edit, publish, use, contribute to Blink however you prefer.
+  Copyright (C) 2025-2026 Aperture OS
+
+  This program is free software: you can redistribute it and/or modify
+  it under the terms of the Apache 2.0 License as published by
+  the Apache Software Foundation, either version 2.0 of the License, or
+  any later version.
+
+  This program is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <https://www.apache.org/licenses/LICENSE-2.0>.
+*/
+
+package main
+
+import "testing"
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.0", "1.0", 0},
+		{"1.0", "1.1", -1},
+		{"1.10", "1.9", 1},
+		{"1.0.1", "1.0", 1},
+		{"1.0.1", "1.0.a", 1},
+		{"1.0a", "1.0b", -1},
+		{"1_0", "1.0", 0},
+		{"001", "1", 0},
+
+		// "~" is a pre-release, before even the end of the string
+		{"1.0~rc1", "1.0", -1},
+		{"1.0~rc1", "1.0~rc2", -1},
+		{"1.0~~", "1.0~", -1},
+
+		// "^" is a post-release snapshot, after the end of the string
+		// but before the next segment
+		{"1.0^git1", "1.0", 1},
+		{"1.0^git1", "1.0.1", -1},
+		{"1.0^git1", "1.0^git2", -1},
+		{"1.0~rc1", "1.0^git1", -1},
+	}
+
+	for _, tt := range tests {
+		if got := compareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := compareVersions(tt.b, tt.a); got != -tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.b, tt.a, got, -tt.want)
+		}
+	}
+}
+
+func TestCompareEVR(t *testing.T) {
+	tests := []struct {
+		a, b pkgVersion
+		want int
+	}{
+		{pkgVersion{0, "1.0", 1}, pkgVersion{0, "1.0", 1}, 0},
+		{pkgVersion{0, "1.0", 1}, pkgVersion{0, "1.0", 2}, -1},
+		{pkgVersion{0, "1.1", 1}, pkgVersion{0, "1.0", 9}, 1},
+		{pkgVersion{1, "0.1", 0}, pkgVersion{0, "9.9", 9}, 1},
+	}
+
+	for _, tt := range tests {
+		if got := compareEVR(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareEVR(%s, %s) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestParseEVR(t *testing.T) {
+	tests := []struct {
+		in         string
+		want       pkgVersion
+		hasRelease bool
+		wantErr    bool
+	}{
+		{in: "1.2.3", want: pkgVersion{0, "1.2.3", 0}},
+		{in: "1.2.3-4", want: pkgVersion{0, "1.2.3", 4}, hasRelease: true},
+		{in: "2:1.2.3", want: pkgVersion{2, "1.2.3", 0}},
+		{in: "2:1.2.3-1700000000", want: pkgVersion{2, "1.2.3", 1700000000}, hasRelease: true},
+		{in: "1.0~rc1", want: pkgVersion{0, "1.0~rc1", 0}},
+		{in: "", wantErr: true},
+		{in: "x:1.0", wantErr: true},
+		{in: "1.0-beta", wantErr: true},
+
+		// "-" is the release separator, a date has to be 2024.01.15
+		{in: "2024-01-15", wantErr: true},
+		{in: "2024.01.15-3", want: pkgVersion{0, "2024.01.15", 3}, hasRelease: true},
+	}
+
+	for _, tt := range tests {
+		got, hasRelease, err := parseEVR(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseEVR(%q) = %s, want an error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseEVR(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want || hasRelease != tt.hasRelease {
+			t.Errorf("parseEVR(%q) = %#v, %v, want %#v, %v", tt.in, got, hasRelease, tt.want, tt.hasRelease)
+		}
+	}
+}
+
+func TestConstraints(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    pkgVersion
+		want       bool
+	}{
+		{"", pkgVersion{0, "1.0", 1}, true},
+		{"*", pkgVersion{0, "1.0", 1}, true},
+		{"1.0", pkgVersion{0, "1.0", 5}, true},
+		{"=1.0", pkgVersion{0, "1.0.1", 1}, false},
+		{"==1.0", pkgVersion{0, "1.0", 1}, true},
+		{"!=1.0", pkgVersion{0, "1.0", 1}, false},
+		{">=3.0, <4", pkgVersion{0, "3.2", 1}, true},
+		{">=3.0, <4", pkgVersion{0, "4.0", 1}, false},
+		{">=3.0, <4", pkgVersion{0, "2.9", 1}, false},
+		{">1.0", pkgVersion{0, "1.0^git1", 1}, true},
+		{"<1.0", pkgVersion{0, "1.0~rc1", 1}, true},
+		{"<=1.0", pkgVersion{0, "1.0", 9}, true},
+
+		// a release only counts when the constraint has one
+		{"=1.0-2", pkgVersion{0, "1.0", 2}, true},
+		{"=1.0-2", pkgVersion{0, "1.0", 3}, false},
+		{">=1.0-2", pkgVersion{0, "1.0", 1}, false},
+
+		// the epoch outranks the version
+		{">=2.0", pkgVersion{1, "1.0", 1}, true},
+		{"<1:0.1", pkgVersion{0, "9.9", 1}, true},
+	}
+
+	for _, tt := range tests {
+		set, err := parseConstraints(tt.constraint)
+		if err != nil {
+			t.Errorf("parseConstraints(%q): %v", tt.constraint, err)
+			continue
+		}
+		if got := set.satisfiedBy(tt.version); got != tt.want {
+			t.Errorf("%q satisfiedBy(%s) = %v, want %v", tt.constraint, tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestParseConstraintsErrors(t *testing.T) {
+	for _, s := range []string{">=", ">=1.0,", "=2024-01-15", ">=1.0-rc1", "~>1.0"} {
+		if set, err := parseConstraints(s); err == nil {
+			t.Errorf("parseConstraints(%q) = %v, want an error", s, set)
+		}
+	}
+}